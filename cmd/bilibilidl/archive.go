@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	archiveFile string
+	// downloadArchive is nil without --download-archive
+	downloadArchive *archive
+)
+
+func addArchiveFlag(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(&archiveFile, "download-archive", "", "Record the downloaded videos in the file and skip the ones already in it.")
+}
+
+// openDownloadArchive opens the archive of --download-archive if it is given
+func openDownloadArchive() error {
+	if len(archiveFile) == 0 {
+		return nil
+	}
+	a, err := openArchive(archiveFile)
+	if err != nil {
+		return err
+	}
+	downloadArchive = a
+	return nil
+}
+
+// archive records the downloaded videos, a line is "<bvid> <cid> <quality> <codec>",
+// only bvid and cid are compared so another quality of a video is not downloaded again
+type archive struct {
+	file    string
+	mu      sync.Mutex
+	entries map[string]bool
+}
+
+func openArchive(file string) (*archive, error) {
+	a := &archive{file: file, entries: make(map[string]bool)}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		cid, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive line: %s", scanner.Text())
+		}
+		a.entries[archiveKey(fields[0], cid)] = true
+	}
+	return a, scanner.Err()
+}
+
+func archiveKey(bvID string, cid int64) string {
+	return fmt.Sprintf("%s %d", bvID, cid)
+}
+
+// has reports whether the video is in the archive, it is always false for a nil archive
+func (a *archive) has(bvID string, cid int64) bool {
+	if a == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.entries[archiveKey(bvID, cid)]
+}
+
+// record appends the video to the file at once so an interrupted batch keeps what is done
+func (a *archive) record(bvID string, cid int64, s videoStream) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := archiveKey(bvID, cid)
+	if a.entries[key] {
+		return nil
+	}
+	f, err := os.OpenFile(a.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	codec := s.codec.String()
+	if len(codec) == 0 {
+		codec = "-"
+	}
+	if _, err = fmt.Fprintf(f, "%s %s %s\n", key, s.quality, codec); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	a.entries[key] = true
+	return nil
+}
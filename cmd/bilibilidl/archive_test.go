@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchive(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "archive.txt")
+	a, err := openArchive(file)
+	assert.NoError(t, err)
+	assert.False(t, a.has("BV1xx411c7mD", 1))
+
+	assert.NoError(t, a.record("BV1xx411c7mD", 1, videoStream{quality: bilibili.Qn1080P, codec: bilibili.CodecidHEVC}))
+	assert.NoError(t, a.record("BV1xx411c7mD", 2, videoStream{quality: bilibili.Qn720P}))
+	// recorded once
+	assert.NoError(t, a.record("BV1xx411c7mD", 1, videoStream{quality: bilibili.Qn4k}))
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "BV1xx411c7mD 1 1080P HEVC\nBV1xx411c7mD 2 720P -\n", string(data))
+
+	a, err = openArchive(file)
+	assert.NoError(t, err)
+	assert.True(t, a.has("BV1xx411c7mD", 1))
+	assert.True(t, a.has("BV1xx411c7mD", 2))
+	assert.False(t, a.has("BV1xx411c7mD", 3))
+
+	// without --download-archive nothing is skipped or recorded
+	var none *archive
+	assert.False(t, none.has("BV1xx411c7mD", 1))
+	assert.NoError(t, none.record("BV1xx411c7mD", 1, videoStream{}))
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/sirupsen/logrus"
+	"github.com/vbauerster/mpb/v5"
+)
+
+var (
+	allParts       bool
+	partRanges     string
+	selectMultiple bool
+	jobs           int
+)
+
+func init() {
+	downloadCmd.Flags().BoolVar(&allParts, "all", false, "Download all pages of the video or all episodes of the season.")
+	downloadCmd.Flags().StringVar(&partRanges, "pages", "", "The pages or episodes to download, e.g. 1-5,8.")
+	downloadCmd.Flags().BoolVarP(&selectMultiple, "multiple", "m", false, "Select several pages or episodes in the prompt.")
+	downloadCmd.Flags().IntVarP(&jobs, "jobs", "j", 1, "How many pages or episodes are downloaded at the same time.")
+}
+
+// selectIndexes returns the indexes of the selected rows from --all, --pages, the number (starts from 1)
+// of --page/--episode or --yes, and prompts when none of them is given
+func selectIndexes(title string, rows []string, number int) ([]int, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("nothing to download")
+	}
+	switch {
+	case allParts:
+		indexes := make([]int, 0, len(rows))
+		for i := range rows {
+			indexes = append(indexes, i)
+		}
+		return indexes, nil
+	case len(partRanges) > 0:
+		return parseRanges(partRanges, len(rows))
+	case number > 0:
+		if number > len(rows) {
+			return nil, fmt.Errorf("%d out of range, there are %d", number, len(rows))
+		}
+		return []int{number - 1}, nil
+	case assumeBest:
+		return []int{0}, nil
+	case selectMultiple:
+		indexes, err := multipleSelectList(title, rows)
+		if err != nil {
+			return nil, err
+		}
+		if len(indexes) == 0 {
+			return nil, fmt.Errorf("nothing selected")
+		}
+		return indexes, nil
+	default:
+		index, err := selectList(title, rows)
+		if err != nil {
+			return nil, err
+		}
+		return []int{index}, nil
+	}
+}
+
+// parseRanges parses ranges like 1-5,8 (starting from 1) into sorted distinct indexes (starting from 0)
+func parseRanges(s string, total int) ([]int, error) {
+	var (
+		seen    = make(map[int]bool)
+		indexes = make([]int, 0)
+	)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		from, to := field, field
+		if i := strings.Index(field, "-"); i >= 0 {
+			from, to = field[:i], field[i+1:]
+		}
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid range: %s", field)
+		}
+		end, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil {
+			return nil, fmt.Errorf("invalid range: %s", field)
+		}
+		if start < 1 || end < start || end > total {
+			return nil, fmt.Errorf("range %s out of 1-%d", field, total)
+		}
+		for i := start; i <= end; i++ {
+			if !seen[i-1] {
+				seen[i-1] = true
+				indexes = append(indexes, i-1)
+			}
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("invalid range: %s", s)
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+type partResult struct {
+	part *part
+	file string
+	err  error
+}
+
+// downloadParts downloads the parts with --jobs workers and reports every part at the end,
+// the best qualities are used unless they are given because prompts can not be shared by workers
+func downloadParts(parts []*part, format bilibili.Fnval) error {
+	if len(outputFile) > 0 {
+		logrus.Warnf("--filename is ignored when several parts are downloaded")
+	}
+	if !assumeBest {
+		logrus.Info("Several parts are selected, the best qualities are used unless --video-quality or --audio-quality is given")
+		// only for this batch, the next input prompts again
+		assumeBest = true
+		defer func() { assumeBest = false }()
+	}
+	workers := jobs
+	if workers < 1 {
+		workers = 1
+	}
+	var progress *mpb.Progress
+	if workers > 1 && sharedProgress == nil {
+		// the workers share one container for their bars, which are named after the files,
+		// instead of each drawing its own
+		progress = mpb.New(mpb.WithWidth(64))
+		sharedProgress = progress
+		defer func() { sharedProgress = nil }()
+	}
+	var (
+		results = make([]partResult, len(parts))
+		indexes = make(chan int)
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				p := parts[i]
+				if progress == nil {
+					logrus.Infof("Downloading %02d %s", p.index, p.name)
+				}
+				file, err := downloadPart(p, format, outputOf(p, true))
+				results[i] = partResult{part: p, file: file, err: err}
+			}
+		}()
+	}
+	for i := range parts {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	if progress != nil {
+		progress.Wait()
+	}
+	return summarize(results)
+}
+
+func summarize(results []partResult) error {
+	failed := 0
+	fmt.Println("Summary:")
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+			fmt.Printf("  [failed] %02d %s: %s\n", result.part.index, result.part.name, result.err)
+			continue
+		}
+		if len(result.file) == 0 {
+			fmt.Printf("  [skip]   %02d %s\n", result.part.index, result.part.name)
+			continue
+		}
+		fmt.Printf("  [done]   %02d %s -> %s\n", result.part.index, result.part.name, result.file)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d parts failed", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRanges(t *testing.T) {
+	indexes, err := parseRanges("1-3, 8,2", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 7}, indexes)
+
+	_, err = parseRanges("5-3", 10)
+	assert.Error(t, err)
+	_, err = parseRanges("9-11", 10)
+	assert.Error(t, err)
+	_, err = parseRanges("a", 10)
+	assert.Error(t, err)
+}
+
+func TestDownloadParts_RestoreOptions(t *testing.T) {
+	defer func(a *archive, n int) { downloadArchive, jobs = a, n }(downloadArchive, jobs)
+	a, err := openArchive(filepath.Join(t.TempDir(), "archive.txt"))
+	assert.NoError(t, err)
+	parts := []*part{{bvID: "BV1xx411c7mD", cid: 1}, {bvID: "BV1xx411c7mD", cid: 2}}
+	for _, p := range parts {
+		assert.NoError(t, a.record(p.bvID, p.cid, videoStream{}))
+	}
+	downloadArchive, jobs = a, 2
+
+	// the archived parts are skipped without downloading
+	assert.NoError(t, downloadParts(parts, bilibili.FnvalDash))
+	assert.False(t, assumeBest)
+	assert.Nil(t, sharedProgress)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/misssonder/bilibili/pkg/danmaku"
+	"github.com/misssonder/bilibili/pkg/video"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	danmakuFormatASS = "ass"
+	danmakuFormatXML = "xml"
+)
+
+var (
+	danmakuFormat string
+	withDanmaku   bool
+)
+
+var danmakuCmd = &cobra.Command{
+	Use:   "danmaku",
+	Short: "Download danmaku of bilibili video through url/BVID/AVID.",
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkDir(); err != nil {
+			return err
+		}
+		if err := checkDanmakuFormat(); err != nil {
+			return err
+		}
+		return login()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := video.ExtractBvID(args[0])
+		exitOnError(err)
+		parts, err := selectParts(id)
+		exitOnError(err)
+		for _, p := range parts {
+			file := outputFile
+			if len(file) == 0 || len(parts) > 1 {
+				file = p.fileName(len(parts) > 1, danmakuFormat)
+			}
+			exitOnError(saveDanmaku(p, path.Join(outputDir, file)))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(danmakuCmd)
+	danmakuCmd.Flags().StringVarP(&outputFile, "filename", "o", "", "The output file.")
+	danmakuCmd.Flags().StringVarP(&outputDir, "directory", "d", ".", "The output directory.")
+	danmakuCmd.Flags().StringVar(&danmakuFormat, "danmaku-format", danmakuFormatASS, "The danmaku format (ass/xml).")
+	downloadCmd.Flags().BoolVar(&withDanmaku, "danmaku", false, "Download the danmaku next to the video.")
+	downloadCmd.Flags().StringVar(&danmakuFormat, "danmaku-format", danmakuFormatASS, "The danmaku format (ass/xml).")
+}
+
+func checkDanmakuFormat() error {
+	switch danmakuFormat {
+	case danmakuFormatASS, danmakuFormatXML:
+		return nil
+	default:
+		return fmt.Errorf("invalid danmaku format: %s", danmakuFormat)
+	}
+}
+
+// danmakuFileOf returns <name>.danmaku.<format> next to videoFile
+func danmakuFileOf(videoFile string) string {
+	return fmt.Sprintf("%s.danmaku.%s", strings.TrimSuffix(videoFile, path.Ext(videoFile)), danmakuFormat)
+}
+
+func saveDanmaku(p *part, file string) error {
+	danmakus, err := client.Danmakus(p.cid, p.duration)
+	if err != nil {
+		return err
+	}
+	writer, err := os.OpenFile(file, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	switch danmakuFormat {
+	case danmakuFormatXML:
+		err = danmaku.WriteXML(writer, p.cid, danmakus)
+	default:
+		err = danmaku.WriteASS(writer, danmakus, danmaku.ASSOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	logrus.Infof("%d danmakus saved to %s", len(danmakus), file)
+	return nil
+}
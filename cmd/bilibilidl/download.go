@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	bilibili "github.com/misssonder/bilibili/pkg/client"
-	"github.com/misssonder/bilibili/pkg/errors"
+	"github.com/misssonder/bilibili/pkg/downloader"
+	"github.com/misssonder/bilibili/pkg/filename"
+	"github.com/misssonder/bilibili/pkg/mp4"
 	"github.com/misssonder/bilibili/pkg/video"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -20,14 +26,30 @@ import (
 )
 
 var (
-	outputFile string
-	outputDir  string
+	outputFile       string
+	outputDir        string
+	continueDownload bool
+	connections      int
+	chunkSize        string
+	probeMirrors     bool
+	merger           string
+
+	// sharedProgress holds the bars of all downloads when it is set, onMerge is called before a part is merged
+	sharedProgress *mpb.Progress
+	onMerge        func(p *part)
+	// merging maps the files being downloaded to their parts for onMerge
+	merging sync.Map
+)
+
+const (
+	mergerNative = "native"
+	mergerFFmpeg = "ffmpeg"
 )
 
 var downloadCmd = &cobra.Command{
-	Use:   "download",
+	Use:   "download [url/BVID/AVID/ep/ss...]",
 	Short: "Download bilibili video through url/BVID/AVID.",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.ArbitraryArgs,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		if err := checkDir(); err != nil {
 			return err
@@ -35,47 +57,78 @@ var downloadCmd = &cobra.Command{
 		if err := checkOutputFormat(); err != nil {
 			return err
 		}
+		if err := checkSubtitleFormat(); err != nil {
+			return err
+		}
+		if err := checkDanmakuFormat(); err != nil {
+			return err
+		}
+		if err := checkSelectOptions(); err != nil {
+			return err
+		}
+		if err := checkOutputTemplate(); err != nil {
+			return err
+		}
+		if err := checkIfExists(); err != nil {
+			return err
+		}
+		if err := openDownloadArchive(); err != nil {
+			return err
+		}
 		return login()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		id, err := video.ExtractBvID(args[0])
-		if err != nil {
-			exitOnError(err)
+		inputs, err := readInputs(args, os.Stdin)
+		exitOnError(err)
+		if usesStdin(args) {
+			assumeBest = true
 		}
-		exitOnError(download(id))
+		exitOnError(downloadInputs(inputs))
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(downloadCmd)
-	downloadCmd.Flags().StringVarP(&outputFile, "filename", "o", "", "The output file.")
-	downloadCmd.Flags().StringVarP(&outputDir, "directory", "d", ".", "The output directory.")
-}
-
-func selectVideoInfo(info *VideoInfo) (Page, error) {
-	pages := info.Pages
-	rows := make([]string, 0, len(pages))
-	for i, page := range pages {
-		rows = append(rows, fmt.Sprintf("%d. %s", i+1, page.Part))
+// downloadInputs downloads the inputs one by one, a failed one does not stop the others
+func downloadInputs(inputs []string) error {
+	if len(inputs) == 1 {
+		id, err := video.ExtractBvID(inputs[0])
+		if err != nil {
+			return err
+		}
+		return download(id)
 	}
-	selectedPage, err := selectList("Please select page", rows)
-	if err != nil {
-		return Page{}, err
+	if len(outputFile) > 0 {
+		logrus.Warnf("--filename is ignored when several videos are downloaded")
+		outputFile = ""
 	}
-	return pages[selectedPage], nil
-}
-
-func selectSeasonInfo(info *SeasonInfo) (Episode, error) {
-	episodes := info.Episodes
-	rows := make([]string, 0, len(episodes))
-	for i, episode := range episodes {
-		rows = append(rows, fmt.Sprintf("%d. %s", i+1, episode.Title))
+	failed := 0
+	for i, input := range inputs {
+		logrus.Infof("[%d/%d] %s", i+1, len(inputs), input)
+		id, err := video.ExtractBvID(input)
+		if err == nil {
+			err = download(id)
+		}
+		if err != nil {
+			failed++
+			logrus.Errorf("Download %s failed: %v", input, err)
+		}
 	}
-	selectedPage, err := selectList("Please select episode", rows)
-	if err != nil {
-		return Episode{}, err
+	if failed > 0 {
+		return fmt.Errorf("%d of %d videos failed", failed, len(inputs))
 	}
-	return episodes[selectedPage], nil
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(downloadCmd)
+	downloadCmd.Flags().StringVarP(&outputFile, "filename", "o", "", "The output file.")
+	addInputFlag(downloadCmd.Flags())
+	addArchiveFlag(downloadCmd.Flags())
+	downloadCmd.Flags().StringVarP(&outputDir, "directory", "d", ".", "The output directory.")
+	downloadCmd.Flags().BoolVarP(&continueDownload, "continue", "c", false, "Resume the partial downloads left by a previous run.")
+	downloadCmd.Flags().IntVarP(&connections, "connections", "n", 4, "How many connections download a file at the same time.")
+	downloadCmd.Flags().StringVar(&merger, "merger", mergerNative, "How the dash video and audio are merged (native/ffmpeg).")
+	downloadCmd.Flags().BoolVar(&probeMirrors, "probe-mirrors", false, "Rank the CDN mirrors of a stream by a quick probe before downloading.")
+	downloadCmd.Flags().StringVar(&chunkSize, "chunk-size", "4M", "The size of the range fetched by a connection, e.g. 512K or 8M.")
 }
 
 func selectFormat() (bilibili.Fnval, error) {
@@ -83,6 +136,12 @@ func selectFormat() (bilibili.Fnval, error) {
 		"MP4":  bilibili.FnvalMP4,
 		"DASH": bilibili.FnvalDash,
 	}
+	if len(videoFormat) > 0 {
+		return formats[strings.ToUpper(videoFormat)], nil
+	}
+	if assumeBest {
+		return bilibili.FnvalDash, nil
+	}
 	rows := []string{
 		"MP4",
 		"DASH",
@@ -94,20 +153,19 @@ func selectFormat() (bilibili.Fnval, error) {
 	return formats[rows[format]], nil
 }
 
-func selectMediaQuality(title string, qns []bilibili.Qn) (bilibili.Qn, error) {
-	var marshalQns = func(qns []bilibili.Qn) []bilibili.Qn {
-		qns = stream.NewSliceByOrdered(qns).Distinct().ToSlice()
-		tmp := make([]int, 0, len(qns))
-		for _, qn := range qns {
-			tmp = append(tmp, int(qn))
-		}
-		sort.Ints(tmp)
-		for i, t := range tmp {
-			qns[i] = bilibili.Qn(t)
+// selectMediaQuality returns want (or the closest available one), the best one with --yes, or prompts
+func selectMediaQuality(title string, qns []bilibili.Qn, want bilibili.Qn, qualities []bilibili.Qn) (bilibili.Qn, error) {
+	qns = stream.NewSliceByOrdered(qns).Distinct().ToSlice()
+	sort.SliceStable(qns, func(i, j int) bool {
+		return qualityRank(qns[i], qualities) < qualityRank(qns[j], qualities)
+	})
+	if want != 0 || assumeBest {
+		qn := pickQuality(qns, want, qualities)
+		if want != 0 && qn != want {
+			logrus.Warnf("%s is not available, %s is used instead", want, qn)
 		}
-		return qns
+		return qn, nil
 	}
-	qns = marshalQns(qns)
 	rows := make([]string, 0, len(qns))
 	for _, qn := range qns {
 		rows = append(rows, qn.String())
@@ -119,40 +177,112 @@ func selectMediaQuality(title string, qns []bilibili.Qn) (bilibili.Qn, error) {
 	return qns[selected], nil
 }
 
-func download(id string) error {
-	var (
-		bvID   string
-		cid    int64
-		title  string
-		epid   int64
-		season = video.IsSSID(id) || video.IsEpID(id)
-	)
+// part is the page of a video or the episode of a season
+type part struct {
+	season bool
+	bvID   string
+	cid    int64
+	epID   int64
+	// title is the video or season title, index starts from 1 and name is the page part or episode title
+	title    string
+	index    int
+	name     string
+	duration time.Duration
+	// aid, owner and pubdate are only used by output templates
+	aid      int
+	owner    string
+	ownerMid int
+	pubdate  time.Time
+	// file is the name given when the part was queued and job is the id of its queue job
+	file string
+	job  int
+}
+
+// fileName is <title>.<ext> for a video page or <episode>.<ext> for an episode,
+// a batch of parts also puts the index and name in it
+func (p *part) fileName(batch bool, ext string) string {
+	var name string
+	switch {
+	case batch:
+		name = fmt.Sprintf("%s - %02d %s.%s", p.title, p.index, p.name, ext)
+	case p.season:
+		name = fmt.Sprintf("%s.%s", p.name, ext)
+	default:
+		name = fmt.Sprintf("%s.%s", p.title, ext)
+	}
+	return filename.Sanitize(name, fileNameBytes)
+}
+
+func selectParts(id string) ([]*part, error) {
 	// epid ssid 需要调用v2接口
-	if season {
+	if video.IsSSID(id) || video.IsEpID(id) {
 		info, err := getSeasonInfo(id)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		episode, err := selectSeasonInfo(info)
-		if err != nil {
-			return err
+		rows := make([]string, 0, len(info.Episodes))
+		for i, episode := range info.Episodes {
+			rows = append(rows, fmt.Sprintf("%d. %s", i+1, episode.Title))
 		}
-		cid = episode.CID
-		bvID = episode.BvID
-		epid = episode.EpID
-		title = episode.Title
-	} else {
-		info, err := getVideoInfo(id)
+		indexes, err := selectIndexes("Please select episode", rows, episodeNumber)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		page, err := selectVideoInfo(info)
-		if err != nil {
-			return err
+		parts := make([]*part, 0, len(indexes))
+		for _, i := range indexes {
+			episode := info.Episodes[i]
+			parts = append(parts, &part{
+				season:   true,
+				bvID:     episode.BvID,
+				cid:      episode.CID,
+				epID:     episode.EpID,
+				title:    info.Title,
+				index:    i + 1,
+				name:     episode.Title,
+				duration: episode.Duration,
+				aid:      episode.AID,
+				owner:    info.ownerName,
+				ownerMid: info.ownerMid,
+				pubdate:  episode.pubdate,
+			})
 		}
-		cid = page.CID
-		bvID = id
-		title = info.Title
+		return parts, nil
+	}
+	info, err := getVideoInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]string, 0, len(info.Pages))
+	for i, page := range info.Pages {
+		rows = append(rows, fmt.Sprintf("%d. %s", i+1, page.Part))
+	}
+	indexes, err := selectIndexes("Please select page", rows, pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]*part, 0, len(indexes))
+	for _, i := range indexes {
+		page := info.Pages[i]
+		parts = append(parts, &part{
+			bvID:     id,
+			cid:      page.CID,
+			title:    info.Title,
+			index:    i + 1,
+			name:     page.Part,
+			duration: page.Duration,
+			aid:      info.AID,
+			owner:    info.ownerName,
+			ownerMid: info.ownerMid,
+			pubdate:  info.pubdate,
+		})
+	}
+	return parts, nil
+}
+
+func download(id string) error {
+	parts, err := selectParts(id)
+	if err != nil {
+		return err
 	}
 
 	format, err := selectFormat()
@@ -160,47 +290,131 @@ func download(id string) error {
 		return err
 	}
 
-	if len(outputFile) == 0 {
-		outputFile = fmt.Sprintf("%s.mp4", title)
+	if len(parts) == 1 {
+		_, err = downloadPart(parts[0], format, outputOf(parts[0], false))
+		return err
 	}
+	return downloadParts(parts, format)
+}
 
+// downloadPart downloads the video of p with its subtitles and danmaku and returns the file,
+// the stream is selected first so the output is known before anything is downloaded
+func downloadPart(p *part, format bilibili.Fnval, output outputFunc) (string, error) {
+	if downloadArchive.has(p.bvID, p.cid) {
+		logrus.Infof("%s is in the download archive, skipped", p.fileName(true, "mp4"))
+		return "", nil
+	}
+	var (
+		m   *media
+		err error
+	)
 	switch format {
 	case bilibili.FnvalMP4:
-		return downloadMp4(season, bvID, cid, epid)
+		m, err = prepareMp4(p.season, p.bvID, p.cid, p.epID)
 	case bilibili.FnvalDash:
-		return downloadDash(season, bvID, cid, epid)
+		m, err = prepareDash(p.season, p.bvID, p.cid, p.epID)
+	default:
+		err = fmt.Errorf("unsupported format %d", format)
 	}
-	return nil
+	if err != nil {
+		return "", err
+	}
+	file := output(m.stream)
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", err
+	}
+	file, ok := resolveExisting(file)
+	if !ok {
+		return "", nil
+	}
+	if onMerge != nil {
+		merging.Store(file, p)
+		defer merging.Delete(file)
+	}
+	if err = m.download(file); err != nil {
+		// free the name reserved by resolveExisting
+		if info, statErr := os.Stat(file); statErr == nil && info.Size() == 0 {
+			_ = os.Remove(file)
+		}
+		return "", err
+	}
+	if err = downloadSubtitles(p.bvID, p.cid, file); err != nil {
+		return "", err
+	}
+	if withDanmaku {
+		if err = saveDanmaku(p, danmakuFileOf(file)); err != nil {
+			return "", err
+		}
+	}
+	return file, downloadArchive.record(p.bvID, p.cid, m.stream)
 }
 
-func downloadMp4(season bool, bvID string, cid int64, epid int64) error {
-	var url string
-	if season {
-		playV2UrlResp, err := client.PlayUrlV2(epid, bilibili.Qn1080P, bilibili.FnvalMP4)
-		if err != nil {
-			return err
+// videoStream is the quality and codec of a downloaded video
+type videoStream struct {
+	quality bilibili.Qn
+	codec   bilibili.Codecid
+}
+
+// media is a selected video, download writes it into file
+type media struct {
+	stream   videoStream
+	download func(file string) error
+}
+
+// prepareMp4 selects the best permitted mp4 quality not better than --video-quality
+func prepareMp4(season bool, bvID string, cid int64, epid int64) (*media, error) {
+	limit, _ := parseQuality(videoQuality, videoQualities)
+	want := limit
+	if want == 0 {
+		want = bilibili.Qn8K
+	}
+	// the first response tells the qualities to choose from, ask again if it is not the best permitted one
+	durls, qualities, current, err := getMp4(season, bvID, cid, epid, want)
+	if err != nil {
+		return nil, err
+	}
+	if qn, ok := bilibili.BestQn(qualities, limit); ok && qn != current.quality {
+		if durls, _, current, err = getMp4(season, bvID, cid, epid, qn); err != nil {
+			return nil, err
 		}
-		url = playV2UrlResp.Result.VideoInfo.Durl[0].URL
-	} else {
-		playUrlResp, err := client.PlayUrl(bvID, cid, bilibili.Qn1080P, bilibili.FnvalMP4)
+	}
+	if limit != 0 && current.quality != limit {
+		logrus.Warnf("%s is not available, %s is used instead", limit, current.quality)
+	}
+	return &media{
+		stream: current,
+		download: func(file string) error {
+			return downloadDurls(durls, file)
+		},
+	}, nil
+}
+
+// getMp4 returns the durls of qn, the qualities the user is permitted to and the stream of the durls
+func getMp4(season bool, bvID string, cid int64, epid int64, qn bilibili.Qn) ([]bilibili.Durl, []bilibili.Qn, videoStream, error) {
+	login, vip := userStatus()
+	if season {
+		playUrlResp, err := client.PlayUrlV2(epid, qn, bilibili.FnvalMP4)
 		if err != nil {
-			return err
+			return nil, nil, videoStream{}, err
 		}
-		url = playUrlResp.Data.Durl[0].URL
+		videoInfo := playUrlResp.Result.VideoInfo
+		current := videoStream{quality: bilibili.Qn(videoInfo.Quality), codec: bilibili.Codecid(videoInfo.VideoCodecid)}
+		return videoInfo.Durl, playUrlResp.PermittedQualities(login, vip), current, nil
 	}
-	writer, err := getDownloadDestFile(outputDir, outputFile)
+	playUrlResp, err := client.PlayUrl(bvID, cid, qn, bilibili.FnvalMP4)
 	if err != nil {
-		return err
+		return nil, nil, videoStream{}, err
 	}
-	defer func() {
-		_ = writer.Close()
-	}()
-	return downloadMedia("Video", url, writer)
+	current := videoStream{quality: bilibili.Qn(playUrlResp.Data.Quality), codec: bilibili.Codecid(playUrlResp.Data.VideoCodecid)}
+	return playUrlResp.Data.Durl, playUrlResp.PermittedQualities(login, vip), current, nil
 }
 
-func downloadDash(season bool, bvID string, cid int64, epid int64) error {
-	if err := checkFFmpeg(); err != nil {
-		return err
+// prepareDash selects the video and audio streams of dash
+func prepareDash(season bool, bvID string, cid int64, epid int64) (*media, error) {
+	if merger == mergerFFmpeg {
+		if err := checkFFmpeg(); err != nil {
+			return nil, err
+		}
 	}
 	var (
 		err  error
@@ -208,21 +422,17 @@ func downloadDash(season bool, bvID string, cid int64, epid int64) error {
 	)
 	if season {
 		dash, err = getSeasonDash(epid)
-		if err != nil {
-			return err
-		}
-		return downloadFromDash(dash)
 	} else {
 		dash, err = getVideoDash(bvID, cid)
-		if err != nil {
-			return err
-		}
-		return downloadFromDash(dash)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return selectDash(dash)
 }
 
 func getSeasonDash(epid int64) (*bilibili.Dash, error) {
-	playUrlResp, err := client.PlayUrlV2(epid, 0, bilibili.FnvalDash)
+	playUrlResp, err := client.PlayUrlV2(epid, 0, bilibili.FnvalDashAll)
 	if err != nil {
 		return nil, err
 	}
@@ -230,92 +440,138 @@ func getSeasonDash(epid int64) (*bilibili.Dash, error) {
 }
 
 func getVideoDash(bvID string, cid int64) (*bilibili.Dash, error) {
-	playUrlResp, err := client.PlayUrl(bvID, cid, 0, bilibili.FnvalDash)
+	playUrlResp, err := client.PlayUrl(bvID, cid, 0, bilibili.FnvalDashAll)
 	if err != nil {
 		return nil, err
 	}
 	return &playUrlResp.Data.Dash, nil
 }
 
-func downloadFromDash(dash *bilibili.Dash) error {
+func selectDash(dash *bilibili.Dash) (*media, error) {
+	video, err := selectDashVideo(dash)
+	if err != nil {
+		return nil, err
+	}
+	audio, err := selectDashAudio(dash)
+	if err != nil {
+		return nil, err
+	}
+	return &media{
+		stream: videoStream{quality: bilibili.Qn(video.ID), codec: video.Codecid},
+		download: func(file string) error {
+			return downloadFromDash(video, audio, file)
+		},
+	}, nil
+}
+
+func downloadFromDash(video, audio *bilibili.DashMedia, file string) error {
 	var (
-		selectedVideoQuality bilibili.Qn
-		selectedAudioQuality bilibili.Qn
-		videoTmp             *os.File
-		audioTmp             *os.File
-		err                  error
+		videoTmp = file + ".video.m4s"
+		audioTmp = file + ".audio.m4s"
 	)
-	{
-		videoQualities := make([]bilibili.Qn, 0, len(dash.Video))
-		videoTmp, err = os.CreateTemp(outputDir, "bilibili_video_*.m4s")
-		if err != nil {
-			return err
-		}
-		defer func() {
-			_ = os.Remove(videoTmp.Name())
-		}()
-		for _, v := range dash.Video {
-			videoQualities = append(videoQualities, bilibili.Qn(v.ID))
-		}
-		selectedVideoQuality, err = selectMediaQuality("Please select video quality", videoQualities)
-		if err != nil {
-			return err
-		}
-	}
-	{
-		audioQualities := make([]bilibili.Qn, 0, len(dash.Audio))
-		audioTmp, err = os.CreateTemp(outputDir, "bilibili_audio_*.m4s")
-		if err != nil {
-			return err
-		}
-		defer func() {
-			_ = os.Remove(audioTmp.Name())
-		}()
-		for _, audio := range dash.Audio {
-			audioQualities = append(audioQualities, bilibili.Qn(audio.ID))
-		}
-		selectedAudioQuality, err = selectMediaQuality("Please select audio quality", audioQualities)
-		if err != nil {
-			return err
-		}
+	if err := downloadDashMedia("Video", video, videoTmp); err != nil {
+		return err
 	}
-	if err = downloadMedia("Video", chooseMediaUrl(dash, selectedVideoQuality), videoTmp); err != nil {
+	if err := downloadDashMedia("Audio", audio, audioTmp); err != nil {
 		return err
 	}
-	if err = downloadMedia("Audio", chooseMediaUrl(dash, selectedAudioQuality), audioTmp); err != nil {
+	stop := startMerge(file)
+	defer stop()
+	if err := merge(videoTmp, audioTmp, file); err != nil {
+		// the streams are kept for --continue
 		return err
 	}
-	ins.Start()
-	defer ins.Stop()
-	return merge(videoTmp.Name(), audioTmp.Name())
+	_ = os.Remove(videoTmp)
+	_ = os.Remove(audioTmp)
+	return nil
+}
+
+// downloadDashMedia downloads a stream, a finished one left by a previous run is kept with --continue
+func downloadDashMedia(title string, media *bilibili.DashMedia, file string) error {
+	if _, err := os.Stat(file); err == nil && continueDownload {
+		return nil
+	}
+	return downloadMedia(title, media.URLs(), file)
 }
 
-func chooseMediaUrl(dash *bilibili.Dash, qn bilibili.Qn) string {
-	if qn > 2048 {
-		for _, audio := range dash.Audio {
-			if audio.ID == int(qn) {
-				return audio.BaseURL
-			}
+// selectDashVideo selects the video quality and then the codec by --codec, or prompts for them
+func selectDashVideo(dash *bilibili.Dash) (*bilibili.DashMedia, error) {
+	if len(dash.Video) == 0 {
+		return nil, fmt.Errorf("no video stream")
+	}
+	want, _ := parseQuality(videoQuality, videoQualities)
+	qn, err := selectMediaQuality("Please select video quality", dash.VideoQualities(), want, videoQualities)
+	if err != nil {
+		return nil, err
+	}
+	codecs, _ := parseCodecs(videoCodec)
+	if available := dash.VideoCodecs(qn); len(codecs) == 0 && !assumeBest && len(available) > 1 {
+		rows := make([]string, 0, len(available))
+		for _, codec := range available {
+			rows = append(rows, codec.String())
 		}
-		return dash.Audio[0].BaseURL
-	} else {
-		for _, v := range dash.Video {
-			if v.ID == int(qn) {
-				return v.BaseURL
-			}
+		selected, err := selectList("Please select video codec", rows)
+		if err != nil {
+			return nil, err
 		}
-		return dash.Video[0].BaseURL
+		codecs = []bilibili.Codecid{available[selected]}
 	}
+	video, ok := dash.SelectVideo(qn, codecs...)
+	if !ok {
+		return nil, fmt.Errorf("no video stream of %s", qn)
+	}
+	if len(codecs) > 0 && video.Codecid != codecs[0] {
+		logrus.Warnf("%s of %s is not available, %s is used instead", codecs[0], qn, video.Codecid)
+	}
+	return video, nil
+}
 
+func selectDashAudio(dash *bilibili.Dash) (*bilibili.DashMedia, error) {
+	qualities := dash.AudioQualities()
+	if len(qualities) == 0 {
+		return nil, fmt.Errorf("no audio stream")
+	}
+	want, _ := parseQuality(audioQuality, audioQualities)
+	qn, err := selectMediaQuality("Please select audio quality", qualities, want, audioQualities)
+	if err != nil {
+		return nil, err
+	}
+	audio, ok := dash.SelectAudio(qn)
+	if !ok {
+		return nil, fmt.Errorf("no audio stream of %s", qn)
+	}
+	return audio, nil
+}
+
+// startMerge notifies onMerge and spins until the returned function is called,
+// the spinner is not shown with sharedProgress as it would break the bars
+func startMerge(file string) func() {
+	if p, ok := merging.Load(file); ok && onMerge != nil {
+		onMerge(p.(*part))
+	}
+	if sharedProgress != nil {
+		return func() {}
+	}
+	ins.Start()
+	return ins.Stop
 }
 
-func merge(video, audio string) error {
+// merge combines the video and audio streams into file with the pure-Go remuxer, or ffmpeg with --merger ffmpeg
+func merge(video, audio, file string) error {
+	if merger == mergerNative {
+		err := mp4.RemuxFiles(file, video, audio)
+		if errors.Is(err, mp4.ErrNotFragmented) {
+			return fmt.Errorf("%w, try --merger %s", err, mergerFFmpeg)
+		}
+		return err
+	}
 	cmd := exec.Command("ffmpeg", "-y",
 		"-i", video,
 		"-i", audio,
 		"-c", "copy", // Just copy without re-encoding
+		"-strict", "unofficial", // Allow FLAC and Dolby Vision in mp4
 		"-shortest", // Finish encoding when the shortest input stream ends
-		path.Join(outputDir, outputFile),
+		file,
 		"-loglevel", "warning",
 	)
 	cmd.Stderr = os.Stderr
@@ -323,52 +579,86 @@ func merge(video, audio string) error {
 	return cmd.Run()
 }
 
-func getDownloadDestFile(dir, f string) (*os.File, error) {
-	filePath := path.Join(dir, f)
-	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+// downloadMedia downloads file from urls (the base url and backup urls), the partial file is kept on failure for --continue
+func downloadMedia(title string, urls []string, file string) error {
+	progress, options := sharedProgress, []mpb.BarOption{mpb.BarRemoveOnComplete()}
+	if progress == nil {
+		progress, options = mpb.New(mpb.WithWidth(64)), nil
+		defer progress.Wait()
+	} else {
+		// tell the files of the workers apart
+		title = fmt.Sprintf("%s %s", title, truncate(path.Base(file), 32))
+	}
+	bar := progress.AddBar(
+		0,
+		append(options,
+			mpb.PrependDecorators(
+				decor.Name(fmt.Sprintf("%s:", title)),
+				decor.OnComplete(
+					decor.Name("download... "), "done ",
+				),
+				decor.CountersKibiByte("% .2f / % .2f"),
+				decor.Percentage(decor.WCSyncSpace),
+			),
+			mpb.AppendDecorators(
+				decor.EwmaETA(decor.ET_STYLE_GO, 90),
+				decor.Name(" | "),
+				decor.EwmaSpeed(decor.UnitKiB, "% .2f", 60),
+			),
+		)...,
+	)
+	err := newDownloader().DownloadURLs(context.Background(), urls, file, &barProgress{bar: bar})
 	if err != nil {
-		return nil, err
+		bar.Abort(sharedProgress != nil)
+	} else {
+		bar.SetTotal(bar.Current(), true)
 	}
-	return file, nil
+	return err
 }
 
-func downloadMedia(title, url string, writer io.Writer) error {
-	cli := &http.Client{}
-	request, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return err
-	}
-	request.Header.Add("referer", "https://www.bilibili.com")
-	resp, err := cli.Do(request)
-	if err != nil {
-		return err
+// truncate keeps the first n runes of s
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return errors.ErrUnexpectedStatusCode(resp.StatusCode)
-	}
-	progress := mpb.New(mpb.WithWidth(64))
-	bar := progress.AddBar(
-		resp.ContentLength,
+	return string(runes[:n]) + "…"
+}
 
-		mpb.PrependDecorators(
-			decor.Name(fmt.Sprintf("%s:", title)),
-			decor.OnComplete(
-				decor.Name("download... "), "done ",
-			),
-			decor.CountersKibiByte("% .2f / % .2f"),
-			decor.Percentage(decor.WCSyncSpace),
-		),
-		mpb.AppendDecorators(
-			decor.EwmaETA(decor.ET_STYLE_GO, 90),
-			decor.Name(" | "),
-			decor.EwmaSpeed(decor.UnitKiB, "% .2f", 60),
-		),
+func newDownloader() *downloader.Downloader {
+	size, _ := parseSize(chunkSize)
+	return downloader.New(
+		downloader.WithResume(continueDownload),
+		downloader.WithConnections(connections),
+		downloader.WithChunkSize(size),
+		downloader.WithProbe(probeMirrors),
 	)
-	reader := bar.ProxyReader(resp.Body)
-	_, err = io.Copy(writer, reader)
-	progress.Wait()
-	return err
+}
+
+// barProgress shows the progress of a download on a bar, Add is called by every connection
+type barProgress struct {
+	bar   *mpb.Bar
+	mu    sync.Mutex
+	start time.Time
+}
+
+func (p *barProgress) Start(total, completed int64) {
+	if total < 0 {
+		total = 0
+	}
+	p.bar.SetTotal(total, false)
+	p.bar.SetCurrent(completed)
+	p.mu.Lock()
+	p.start = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *barProgress) Add(n int) {
+	p.bar.IncrBy(n)
+	p.mu.Lock()
+	p.bar.DecoratorEwmaUpdate(time.Since(p.start))
+	p.start = time.Now()
+	p.mu.Unlock()
 }
 
 func checkFFmpeg() error {
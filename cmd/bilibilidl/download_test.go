@@ -1,19 +1,94 @@
 package main
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestDownload(t *testing.T) {
-	file, err := os.OpenFile("video.mp4", os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	err := downloadMedia("", []string{"https://cn-hbwh-cm-01-09.bilivideo.com/upgcxcode/00/39/983613900/983613900_sr1-1-100035.m4s?e=ig8euxZM2rNcNbdlhoNvNC8BqJIzNbfqXBvEqxTEto8BTrNvN0GvT90W5JZMkX_YN0MvXg8gNEV4NC8xNEV4N03eN0B5tZlqNxTEto8BTrNvNeZVuJ10Kj_g2UB02J0mN0B5tZlqNCNEto8BTrNvNC7MTX502C8f2jmMQJ6mqF2fka1mqx6gqj0eN0B599M=&uipk=5&nbs=1&deadline=1675330209&gen=playurlv2&os=bcache&oi=2029904162&trid=0000d71a9e39d2154bd9adf84d593118ac1bu&mid=35988173&platform=pc&upsig=749f0235988fd8ba079349a3e920457f&uparams=e,uipk,nbs,deadline,gen,os,oi,trid,mid,platform&cdnid=10205&bvc=vod&nettype=0&orderid=0,3&buvid=&build=0&agrr=1&bw=1419861&logo=80000000"}, "video.mp4")
 	if err != nil {
 		t.Error(err)
 		return
 	}
-	err = downloadMedia("", "https://cn-hbwh-cm-01-09.bilivideo.com/upgcxcode/00/39/983613900/983613900_sr1-1-100035.m4s?e=ig8euxZM2rNcNbdlhoNvNC8BqJIzNbfqXBvEqxTEto8BTrNvN0GvT90W5JZMkX_YN0MvXg8gNEV4NC8xNEV4N03eN0B5tZlqNxTEto8BTrNvNeZVuJ10Kj_g2UB02J0mN0B5tZlqNCNEto8BTrNvNC7MTX502C8f2jmMQJ6mqF2fka1mqx6gqj0eN0B599M=&uipk=5&nbs=1&deadline=1675330209&gen=playurlv2&os=bcache&oi=2029904162&trid=0000d71a9e39d2154bd9adf84d593118ac1bu&mid=35988173&platform=pc&upsig=749f0235988fd8ba079349a3e920457f&uparams=e,uipk,nbs,deadline,gen,os,oi,trid,mid,platform&cdnid=10205&bvc=vod&nettype=0&orderid=0,3&buvid=&build=0&agrr=1&bw=1419861&logo=80000000", file)
-	if err != nil {
-		t.Error(err)
-		return
+}
+
+func TestSelectDash_KeepStreams(t *testing.T) {
+	var videoRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/video.m4s" {
+			videoRequests++
+			_, _ = w.Write([]byte("video"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	defer func(best, resume bool) { assumeBest, continueDownload = best, resume }(assumeBest, continueDownload)
+	assumeBest, continueDownload = true, false
+	dash := &bilibili.Dash{
+		Video: []bilibili.DashMedia{{ID: int(bilibili.Qn1080P), BaseURL: server.URL + "/video.m4s", Codecid: bilibili.CodecidAVC}},
+		Audio: []bilibili.DashMedia{{ID: int(bilibili.QnAudio192K), BaseURL: server.URL + "/audio.m4s"}},
 	}
+	file := filepath.Join(t.TempDir(), "video.mp4")
+
+	m, err := selectDash(dash)
+	assert.NoError(t, err)
+	assert.Equal(t, videoStream{quality: bilibili.Qn1080P, codec: bilibili.CodecidAVC}, m.stream)
+	err = m.download(file)
+	assert.Error(t, err)
+	data, err := os.ReadFile(file + ".video.m4s")
+	assert.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+
+	requests := videoRequests
+	continueDownload = true
+	err = m.download(file)
+	assert.Error(t, err)
+	assert.Equal(t, requests, videoRequests)
+	assert.FileExists(t, file+".video.m4s")
+}
+
+func TestDownloadPart_SkipExisting(t *testing.T) {
+	var mediaRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/x/web-interface/nav":
+			_, _ = w.Write([]byte(`{"code":-101,"message":"账号未登录","data":{"isLogin":false,"wbi_img":{
+				"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",
+				"sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`))
+		case "/x/player/wbi/playurl":
+			_, _ = w.Write([]byte(`{"code":0,"data":{"quality":80,"dash":{
+				"video":[{"id":80,"base_url":"` + "http://" + r.Host + `/video.m4s","codecid":12}],
+				"audio":[{"id":30280,"base_url":"` + "http://" + r.Host + `/audio.m4s"}]}}}`))
+		default:
+			mediaRequests++
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defer func(c *bilibili.Client, dir, policy string, best bool) {
+		client, outputDir, outputTemplate, ifExists, assumeBest = c, dir, "", policy, best
+	}(client, outputDir, ifExists, assumeBest)
+	client = bilibili.New(bilibili.WithBaseURL(server.URL))
+	outputDir, outputTemplate, ifExists, assumeBest = t.TempDir(), "{title} [{quality} {codec}]", ifExistsSkip, true
+	existing := filepath.Join(outputDir, "video [1080P HEVC].mp4")
+	assert.NoError(t, os.WriteFile(existing, []byte("video"), 0644))
+
+	p := &part{bvID: "BV1xx411c7mD", cid: 1, title: "video"}
+	file, err := downloadPart(p, bilibili.FnvalDash, outputOf(p, false))
+	assert.NoError(t, err)
+	assert.Empty(t, file)
+	assert.Zero(t, mediaRequests)
+	data, err := os.ReadFile(existing)
+	assert.NoError(t, err)
+	assert.Equal(t, "video", string(data))
 }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/downloader"
+	"github.com/misssonder/bilibili/pkg/flv"
+)
+
+// downloadDurls downloads every segment of an mp4/flv stream in order, checks their sizes and md5
+// and joins them into file
+func downloadDurls(durls []bilibili.Durl, file string) error {
+	if len(durls) == 0 {
+		return fmt.Errorf("no media url")
+	}
+	sort.SliceStable(durls, func(i, j int) bool {
+		return durls[i].Order < durls[j].Order
+	})
+	if len(durls) == 1 {
+		return downloadDurl("Video", durls[0], file)
+	}
+	segments := make([]string, 0, len(durls))
+	for i, durl := range durls {
+		segment := fmt.Sprintf("%s.%d%s", file, i+1, segmentExt(durl.URL))
+		if err := downloadDurl(fmt.Sprintf("Video %d/%d", i+1, len(durls)), durl, segment); err != nil {
+			return err
+		}
+		segments = append(segments, segment)
+	}
+	stop := startMerge(file)
+	defer stop()
+	if err := concatSegments(segments, file); err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		_ = os.Remove(segment)
+	}
+	return nil
+}
+
+// downloadDurl downloads a segment, a verified one left by a previous run is kept with --continue
+func downloadDurl(title string, durl bilibili.Durl, file string) error {
+	if continueDownload && durl.Size > 0 && downloader.Verify(file, int64(durl.Size), durl.Md5) == nil {
+		return nil
+	}
+	if err := downloadMedia(title, durl.URLs(), file); err != nil {
+		return err
+	}
+	if err := downloader.Verify(file, int64(durl.Size), durl.Md5); err != nil {
+		_ = os.Remove(file)
+		return err
+	}
+	return nil
+}
+
+// segmentExt returns the extension of the segment url, .flv if it has none
+func segmentExt(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ".flv"
+	}
+	if ext := path.Ext(u.Path); len(ext) > 0 {
+		return ext
+	}
+	return ".flv"
+}
+
+// concatSegments joins the segments with the ffmpeg concat demuxer, or natively if they are flv
+// and ffmpeg is not installed
+func concatSegments(segments []string, file string) error {
+	if err := checkFFmpeg(); err == nil {
+		return ffmpegConcat(segments, file)
+	}
+	for _, segment := range segments {
+		if !strings.EqualFold(path.Ext(segment), ".flv") {
+			return fmt.Errorf("ffmpeg is required to join %s segments", path.Ext(segment))
+		}
+	}
+	return nativeConcat(segments, file)
+}
+
+func ffmpegConcat(segments []string, file string) error {
+	list := file + ".concat.txt"
+	content := &strings.Builder{}
+	for _, segment := range segments {
+		abs, err := filepath.Abs(segment)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(content, "file '%s'\n", strings.ReplaceAll(abs, "'", `'\''`))
+	}
+	if err := os.WriteFile(list, []byte(content.String()), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(list)
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "concat",
+		"-safe", "0",
+		"-i", list,
+		"-c", "copy",
+		file,
+		"-loglevel", "warning",
+	)
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	return cmd.Run()
+}
+
+func nativeConcat(segments []string, file string) error {
+	readers := make([]io.Reader, 0, len(segments))
+	for _, segment := range segments {
+		reader, err := os.Open(segment)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		readers = append(readers, reader)
+	}
+	writer, err := os.OpenFile(file, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	return flv.Concat(writer, readers...)
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmentExt(t *testing.T) {
+	assert.Equal(t, ".flv", segmentExt("https://upos-sz-mirrorcos.bilivideo.com/upgcxcode/1/2/3-1-80.flv?e=1&deadline=2"))
+	assert.Equal(t, ".mp4", segmentExt("https://upos-sz-mirrorcos.bilivideo.com/upgcxcode/1/2/3-1-16.mp4"))
+	assert.Equal(t, ".flv", segmentExt("https://upos-sz-mirrorcos.bilivideo.com/upgcxcode/1/2/3"))
+}
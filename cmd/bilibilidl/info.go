@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/xml"
 	"fmt"
 	"io"
 	"os"
@@ -35,6 +36,11 @@ type VideoInfo struct {
 	CreateTime  string
 	Description string
 	Pages       []Page
+
+	// the owner and the publish time for output templates
+	ownerName string
+	ownerMid  int
+	pubdate   time.Time
 }
 
 type SeasonInfo struct {
@@ -43,6 +49,9 @@ type SeasonInfo struct {
 	Duration    time.Duration
 	Description string
 	Episodes    []Episode
+
+	ownerName string
+	ownerMid  int
 }
 
 type Episode struct {
@@ -53,12 +62,14 @@ type Episode struct {
 	Title     string
 	Duration  time.Duration
 	Dimension Dimension
+
+	pubdate time.Time
 }
 
 var infoCmd = &cobra.Command{
-	Use:   "info",
+	Use:   "info [url/BVID/AVID/ep/ss...]",
 	Short: "Show base info of video.",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.ArbitraryArgs,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		if err := checkOutputFormat(); err != nil {
 			return err
@@ -66,23 +77,78 @@ var infoCmd = &cobra.Command{
 		return login()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if video.IsEpID(args[0]) || video.IsSSID(args[0]) {
-			seasonInfo, err := getSeasonInfo(args[0])
-			exitOnError(err)
-			exitOnError(writeOutput(os.Stdout, seasonInfo, func(w io.Writer) {
-				writeSeasonInfoOutput(w, seasonInfo)
-			}))
-		} else {
-			videoInfo, err := getVideoInfo(args[0])
-			exitOnError(err)
-			exitOnError(writeOutput(os.Stdout, videoInfo, func(w io.Writer) {
-				writeVideoInfoOutput(w, videoInfo)
-			}))
+		inputs, err := readInputs(args, os.Stdin)
+		exitOnError(err)
+		if len(inputs) == 1 {
+			if video.IsEpID(inputs[0]) || video.IsSSID(inputs[0]) {
+				seasonInfo, err := getSeasonInfo(inputs[0])
+				exitOnError(err)
+				exitOnError(writeOutput(os.Stdout, seasonInfo, func(w io.Writer) {
+					writeSeasonInfoOutput(w, seasonInfo)
+				}))
+			} else {
+				videoInfo, err := getVideoInfo(inputs[0])
+				exitOnError(err)
+				exitOnError(writeOutput(os.Stdout, videoInfo, func(w io.Writer) {
+					writeVideoInfoOutput(w, videoInfo)
+				}))
+			}
+			return
 		}
-
+		exitOnError(writeInfoReport(os.Stdout, inputs))
 	},
 }
 
+// InfoReport is the combined info of several inputs
+type InfoReport struct {
+	XMLName xml.Name      `json:"-" yaml:"-" xml:"Infos"`
+	Videos  []*VideoInfo  `json:"Videos,omitempty" yaml:"Videos,omitempty" xml:"Video"`
+	Seasons []*SeasonInfo `json:"Seasons,omitempty" yaml:"Seasons,omitempty" xml:"Season"`
+	Errors  []string      `json:"Errors,omitempty" yaml:"Errors,omitempty" xml:"Error"`
+}
+
+// writeInfoReport writes the info of every input, the failed ones are reported without stopping the others
+func writeInfoReport(w io.Writer, inputs []string) error {
+	report := &InfoReport{}
+	for _, input := range inputs {
+		if video.IsEpID(input) || video.IsSSID(input) {
+			seasonInfo, err := getSeasonInfo(input)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", input, err))
+				continue
+			}
+			report.Seasons = append(report.Seasons, seasonInfo)
+		} else {
+			videoInfo, err := getVideoInfo(input)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", input, err))
+				continue
+			}
+			report.Videos = append(report.Videos, videoInfo)
+		}
+	}
+	err := writeOutput(w, report, func(w io.Writer) {
+		for _, info := range report.Videos {
+			writeVideoInfoOutput(w, info)
+			fmt.Fprintln(w)
+		}
+		for _, info := range report.Seasons {
+			writeSeasonInfoOutput(w, info)
+			fmt.Fprintln(w)
+		}
+		for _, e := range report.Errors {
+			fmt.Fprintln(os.Stderr, e)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d of %d inputs failed", len(report.Errors), len(inputs))
+	}
+	return nil
+}
+
 func getSeasonInfo(id string) (seasonInfo *SeasonInfo, err error) {
 	var info *bilibili.SeasonSectionResp
 	if video.IsSSID(id) {
@@ -108,6 +174,8 @@ func getSeasonInfo(id string) (seasonInfo *SeasonInfo, err error) {
 		Title:       fmt.Sprintf("%s(%s)", info.Result.Title, info.Result.Subtitle),
 		Description: info.Result.Evaluate,
 		Episodes:    make([]Episode, 0),
+		ownerName:   info.Result.UpInfo.Uname,
+		ownerMid:    info.Result.UpInfo.Mid,
 	}
 	for _, episode := range info.Result.Episodes {
 		e := Episode{
@@ -117,6 +185,7 @@ func getSeasonInfo(id string) (seasonInfo *SeasonInfo, err error) {
 			EpID:     int64(episode.ID),
 			Duration: time.Duration(episode.Duration) * time.Millisecond,
 			Title:    episode.LongTitle,
+			pubdate:  time.Unix(int64(episode.PubTime), 0),
 		}
 		if episode.Dimension.Rotate != 0 {
 			e.Dimension.Height = episode.Dimension.Width
@@ -149,6 +218,9 @@ func getVideoInfo(id string) (videoInfo *VideoInfo, err error) {
 		CreateTime:  time.Unix(int64(info.Data.Ctime), 0).Format(time.RFC3339),
 		Description: info.Data.Desc,
 		Pages:       make([]Page, 0),
+		ownerName:   info.Data.Owner.Name,
+		ownerMid:    info.Data.Owner.Mid,
+		pubdate:     time.Unix(int64(info.Data.Pubdate), 0),
 	}
 	for _, p := range info.Data.Pages {
 		page := Page{
@@ -241,6 +313,7 @@ func writeSeasonInfoOutput(w io.Writer, info *SeasonInfo) {
 func init() {
 	rootCmd.AddCommand(infoCmd)
 	addFormatFlag(infoCmd.Flags())
+	addInputFlag(infoCmd.Flags())
 }
 
 func timeString(duration time.Duration) string {
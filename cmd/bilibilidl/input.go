@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// stdinInput as an argument or --input-file reads the inputs from stdin
+const stdinInput = "-"
+
+var inputFile string
+
+func addInputFlag(flagSet *pflag.FlagSet) {
+	flagSet.StringVarP(&inputFile, "input-file", "i", "", "Read url/BVID/AVID/ep/ss from the file, one per line, - is stdin, # starts a comment.")
+}
+
+// readInputs returns the arguments and the lines of --input-file in order without duplicates,
+// an argument of - reads stdin
+func readInputs(args []string, stdin io.Reader) ([]string, error) {
+	var (
+		inputs    = make([]string, 0, len(args))
+		seen      = make(map[string]bool)
+		readStdin bool
+	)
+	add := func(input string) {
+		if !seen[input] {
+			seen[input] = true
+			inputs = append(inputs, input)
+		}
+	}
+	read := func(r io.Reader) error {
+		lines, err := parseInputs(r)
+		for _, line := range lines {
+			add(line)
+		}
+		return err
+	}
+	for _, arg := range args {
+		if arg != stdinInput {
+			add(arg)
+			continue
+		}
+		if !readStdin {
+			readStdin = true
+			if err := read(stdin); err != nil {
+				return nil, err
+			}
+		}
+	}
+	switch {
+	case len(inputFile) == 0:
+	case inputFile == stdinInput:
+		if !readStdin {
+			if err := read(stdin); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		file, err := os.Open(inputFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		if err = read(file); err != nil {
+			return nil, err
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("nothing to do, give url/BVID/AVID/ep/ss as arguments or by --input-file")
+	}
+	return inputs, nil
+}
+
+// parseInputs returns the first field of every line, empty lines and comments starting with # are skipped,
+// so the rest of a line, e.g. the title in an export, is ignored
+func parseInputs(r io.Reader) ([]string, error) {
+	inputs := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		// the fields of csv and tsv exports
+		if fields := strings.Fields(strings.ReplaceAll(line, ",", " ")); len(fields) > 0 {
+			inputs = append(inputs, fields[0])
+		}
+	}
+	return inputs, scanner.Err()
+}
+
+// usesStdin reports whether the inputs are read from stdin, then prompts can not be answered
+func usesStdin(args []string) bool {
+	if inputFile == stdinInput {
+		return true
+	}
+	for _, arg := range args {
+		if arg == stdinInput {
+			return true
+		}
+	}
+	return false
+}
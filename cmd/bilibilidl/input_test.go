@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadInputs(t *testing.T) {
+	defer func() { inputFile = "" }()
+	stdin := strings.NewReader("\ufeff# exported\nBV1xx411c7mD\n\n  https://www.bilibili.com/bangumi/play/ep123 title\nBV1yy411c7mE,second,2\n")
+
+	inputs, err := readInputs([]string{"BV1zz411c7mF", "-", "BV1xx411c7mD"}, stdin)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BV1zz411c7mF",
+		"BV1xx411c7mD",
+		"https://www.bilibili.com/bangumi/play/ep123",
+		"BV1yy411c7mE",
+	}, inputs)
+
+	inputFile = filepath.Join(t.TempDir(), "inputs.txt")
+	assert.NoError(t, os.WriteFile(inputFile, []byte("ss456\n# BV1ignored\n"), 0644))
+	inputs, err = readInputs(nil, strings.NewReader(""))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ss456"}, inputs)
+
+	inputFile = ""
+	_, err = readInputs(nil, strings.NewReader("# nothing\n"))
+	assert.Error(t, err)
+	_, err = readInputs([]string{"-"}, strings.NewReader("# nothing\n"))
+	assert.Error(t, err)
+}
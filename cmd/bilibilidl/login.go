@@ -3,9 +3,12 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 	"strings"
+	"sync"
+	"time"
 
 	bilibili "github.com/misssonder/bilibili/pkg/client"
 	"github.com/sirupsen/logrus"
@@ -13,8 +16,23 @@ import (
 )
 
 var (
-	cookieDir  = os.Getenv("HOME")
-	cookieFile = ".bilibili_cookie.txt"
+	cookieDir        = os.Getenv("HOME")
+	cookieFile       = ".bilibili_cookie.txt"
+	refreshTokenFile = ".bilibili_refresh_token.txt"
+)
+
+var (
+	userStatusOnce sync.Once
+	userLogin      bool
+	userVip        bool
+)
+
+// sessionCheckInterval is how often a long run like queue run refreshes the cookie
+const sessionCheckInterval = 30 * time.Minute
+
+var (
+	sessionMu      sync.Mutex
+	sessionChecked time.Time
 )
 
 var loginCmd = &cobra.Command{
@@ -39,6 +57,11 @@ func isLogin() bool {
 		return false
 	}
 	client.SetCookie(cookie)
+	// an expired SESSDATA is what the refresh token is for
+	keepSession(true)
+	if client.CookieExpired() {
+		return false
+	}
 	info, err := client.NavInfo()
 	if err != nil {
 		return false
@@ -46,6 +69,19 @@ func isLogin() bool {
 	return info.Data.IsLogin
 }
 
+// userStatus returns whether the user is logged in and is a vip, bilibili is asked only once
+func userStatus() (login, vip bool) {
+	userStatusOnce.Do(func() {
+		info, err := client.NavInfo()
+		if err != nil {
+			logrus.Warnf("Get user status failed: %v", err)
+			return
+		}
+		userLogin, userVip = info.Data.IsLogin, info.Data.VipStatus == 1
+	})
+	return userLogin, userVip
+}
+
 func login() error {
 	if !isLogin() {
 		logrus.Info("Please login")
@@ -57,10 +93,10 @@ func login() error {
 			switch resp.LoginStatus {
 			case bilibili.LoginSuccess:
 				client.SetCookie(resp.Cookie)
-				if err = saveCookieFile(resp.Cookie); err != nil {
+				if err = saveCookieFile(client.Cookies()); err != nil {
 					return err
 				}
-				return nil
+				return saveRefreshToken(resp.RefreshToken)
 			case bilibili.LoginExpired:
 				return fmt.Errorf("login qrcode expired")
 			default:
@@ -71,13 +107,60 @@ func login() error {
 	return nil
 }
 
-func saveCookieFile(cookie []string) error {
+// keepSession refreshes the cookie if it was not checked for sessionCheckInterval or force is set,
+// so that a long run does not lose its session
+func keepSession(force bool) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if !force && time.Since(sessionChecked) < sessionCheckInterval {
+		return
+	}
+	sessionChecked = time.Now()
+	if err := refreshCookie(); err != nil {
+		logrus.Warnf("Refresh cookie failed: %v", err)
+	}
+}
+
+// refreshCookie refreshes the cookie with the token saved on login when bilibili asks to
+func refreshCookie() error {
+	token, err := readRefreshToken()
+	if err != nil || len(token) == 0 {
+		return nil
+	}
+	newToken, refreshed, err := client.RefreshCookies(token)
+	if err != nil || !refreshed {
+		return err
+	}
+	logrus.Info("Cookie refreshed")
+	if err = saveCookieFile(client.Cookies()); err != nil {
+		return err
+	}
+	return saveRefreshToken(newToken)
+}
+
+func saveRefreshToken(token string) error {
+	return os.WriteFile(path.Join(cookieDir, refreshTokenFile), []byte(token), 0600)
+}
+
+func readRefreshToken() (string, error) {
+	data, err := os.ReadFile(path.Join(cookieDir, refreshTokenFile))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func saveCookieFile(cookies []*http.Cookie) error {
 	file, err := os.OpenFile(path.Join(cookieDir, cookieFile), os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	_, err = file.Write([]byte(strings.Join(cookie, "\n")))
+	lines := make([]string, 0, len(cookies))
+	for _, cookie := range cookies {
+		lines = append(lines, cookie.String())
+	}
+	_, err = file.Write([]byte(strings.Join(lines, "\n")))
 	return err
 }
 
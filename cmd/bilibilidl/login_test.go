@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepSession(t *testing.T) {
+	var checks int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/x/passport-login/web/cookie/info" {
+			checks++
+		}
+		_, _ = w.Write([]byte(`{"code":0,"data":{"refresh":false}}`))
+	}))
+	defer server.Close()
+
+	defer func(c *bilibili.Client, dir string) {
+		client, cookieDir, sessionChecked = c, dir, time.Time{}
+	}(client, cookieDir)
+	client = bilibili.New(bilibili.WithPassportURL(server.URL))
+	cookieDir = t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(cookieDir, refreshTokenFile), []byte("token"), 0600))
+
+	keepSession(true)
+	assert.Equal(t, 1, checks)
+	// checked recently
+	keepSession(false)
+	assert.Equal(t, 1, checks)
+
+	sessionChecked = time.Now().Add(-sessionCheckInterval)
+	keepSession(false)
+	assert.Equal(t, 2, checks)
+}
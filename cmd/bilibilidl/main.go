@@ -12,7 +12,10 @@ var (
 )
 
 func init() {
-	client = &bilibili.Client{}
+	client = bilibili.New(
+		bilibili.WithRetryPolicy(bilibili.NewExponentialBackoff(3)),
+		bilibili.WithRateLimit(bilibili.NewRateLimiter(5, 5)),
+	)
 }
 
 func main() {
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+)
+
+const (
+	videoFormatMP4  = "mp4"
+	videoFormatDash = "dash"
+)
+
+var (
+	pageNumber    int
+	episodeNumber int
+	videoFormat   string
+	videoQuality  string
+	audioQuality  string
+	videoCodec    string
+	assumeBest    bool
+)
+
+// videoQualities and audioQualities are ordered from the worst to the best
+var (
+	videoQualities = bilibili.VideoQualities()
+	audioQualities = bilibili.AudioQualities()
+)
+
+func init() {
+	downloadCmd.Flags().IntVar(&pageNumber, "page", 0, "The page of the video to download, starts from 1.")
+	downloadCmd.Flags().IntVar(&episodeNumber, "episode", 0, "The episode of the season to download, starts from 1.")
+	downloadCmd.Flags().StringVar(&videoFormat, "format", "", "The video format (mp4/dash).")
+	downloadCmd.Flags().StringVar(&videoQuality, "video-quality", "", "The video quality, e.g. 1080P60 or 4K, a lower one is used if it is not available.")
+	downloadCmd.Flags().StringVar(&audioQuality, "audio-quality", "", "The audio quality, e.g. 192K or Hi-Res.")
+	downloadCmd.Flags().StringVar(&videoCodec, "codec", "", "The preferred video codecs of dash in order, e.g. av1,hevc (avc/hevc/av1), AVC is the fallback.")
+	downloadCmd.Flags().BoolVarP(&assumeBest, "yes", "y", false, "Pick the first page and the best format and qualities instead of prompting.")
+	downloadCmd.Flags().BoolVar(&assumeBest, "best", false, "Alias of --yes.")
+}
+
+func checkSelectOptions() error {
+	switch strings.ToLower(videoFormat) {
+	case "", videoFormatMP4, videoFormatDash:
+	default:
+		return fmt.Errorf("invalid video format: %s", videoFormat)
+	}
+	if _, err := parseCodecs(videoCodec); err != nil {
+		return err
+	}
+	if _, err := parseQuality(videoQuality, videoQualities); err != nil {
+		return err
+	}
+	if _, err := parseQuality(audioQuality, audioQualities); err != nil {
+		return err
+	}
+	if merger != mergerNative && merger != mergerFFmpeg {
+		return fmt.Errorf("invalid merger: %s", merger)
+	}
+	if _, err := parseSize(chunkSize); err != nil {
+		return err
+	}
+	if pageNumber < 0 || episodeNumber < 0 {
+		return fmt.Errorf("page and episode start from 1")
+	}
+	return nil
+}
+
+// parseQuality parses a label like 1080P60 or 192K, or the qn itself, empty s returns 0
+func parseQuality(s string, qualities []bilibili.Qn) (bilibili.Qn, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	qn, err := bilibili.ParseQn(s)
+	if err != nil || qualityRank(qn, qualities) < 0 {
+		return 0, fmt.Errorf("invalid quality: %s", s)
+	}
+	return qn, nil
+}
+
+// qualityRank is the position of qn in qualities, unknown ones rank lowest
+func qualityRank(qn bilibili.Qn, qualities []bilibili.Qn) int {
+	for i, quality := range qualities {
+		if quality == qn {
+			return i
+		}
+	}
+	return -1
+}
+
+// pickQuality returns want if it is available, otherwise the best one not better than want,
+// or the worst available when all of them are better; want 0 means the best available
+func pickQuality(available []bilibili.Qn, want bilibili.Qn, qualities []bilibili.Qn) bilibili.Qn {
+	var (
+		best, below, worst  bilibili.Qn
+		bestRank, belowRank = -2, -2
+		worstRank           = len(qualities)
+		wantRank            = qualityRank(want, qualities)
+	)
+	for _, qn := range available {
+		if want != 0 && qn == want {
+			return qn
+		}
+		rank := qualityRank(qn, qualities)
+		if rank > bestRank {
+			best, bestRank = qn, rank
+		}
+		if rank < worstRank {
+			worst, worstRank = qn, rank
+		}
+		if rank <= wantRank && rank > belowRank {
+			below, belowRank = qn, rank
+		}
+	}
+	switch {
+	case want == 0:
+		return best
+	case below != 0:
+		return below
+	default:
+		return worst
+	}
+}
+
+// parseSize parses a size like 512K, 4M or 1G, a plain number is in bytes
+func parseSize(value string) (int64, error) {
+	units := map[byte]int64{'K': 1 << 10, 'M': 1 << 20, 'G': 1 << 30}
+	s := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B")
+	unit := int64(1)
+	if len(s) > 0 {
+		if u, ok := units[s[len(s)-1]]; ok {
+			unit, s = u, s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	return n * unit, nil
+}
+
+// parseCodecs parses the comma separated codecs in the order of preference
+func parseCodecs(s string) ([]bilibili.Codecid, error) {
+	codecs := make([]bilibili.Codecid, 0)
+	for _, field := range strings.Split(s, ",") {
+		if len(strings.TrimSpace(field)) == 0 {
+			continue
+		}
+		codec, err := bilibili.ParseCodec(field)
+		if err != nil {
+			return nil, err
+		}
+		codecs = append(codecs, codec)
+	}
+	return codecs, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuality(t *testing.T) {
+	qn, err := parseQuality("1080p60", videoQualities)
+	assert.NoError(t, err)
+	assert.Equal(t, bilibili.Qn1080P60, qn)
+
+	qn, err = parseQuality("30280", audioQualities)
+	assert.NoError(t, err)
+	assert.Equal(t, bilibili.QnAudio192K, qn)
+
+	qn, err = parseQuality("", videoQualities)
+	assert.NoError(t, err)
+	assert.Equal(t, bilibili.Qn(0), qn)
+
+	_, err = parseQuality("192K", videoQualities)
+	assert.Error(t, err)
+}
+
+func TestPickQuality(t *testing.T) {
+	available := []bilibili.Qn{bilibili.Qn480P, bilibili.Qn720P, bilibili.Qn1080P}
+	assert.Equal(t, bilibili.Qn1080P, pickQuality(available, 0, videoQualities))
+	assert.Equal(t, bilibili.Qn720P, pickQuality(available, bilibili.Qn720P, videoQualities))
+	assert.Equal(t, bilibili.Qn1080P, pickQuality(available, bilibili.Qn4k, videoQualities))
+	assert.Equal(t, bilibili.Qn480P, pickQuality(available, bilibili.Qn360P, videoQualities))
+
+	audios := []bilibili.Qn{bilibili.QnAudio192K, bilibili.QnAudioDolby, bilibili.QnAudio64K}
+	assert.Equal(t, bilibili.QnAudioDolby, pickQuality(audios, 0, audioQualities))
+}
+
+func TestParseSize(t *testing.T) {
+	size, err := parseSize("4M")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4<<20), size)
+
+	size, err = parseSize("512kb")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(512<<10), size)
+
+	size, err = parseSize("1000")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), size)
+
+	_, err = parseSize("-1M")
+	assert.Error(t, err)
+}
+
+func TestParseCodecs(t *testing.T) {
+	codecs, err := parseCodecs("av1, hevc")
+	assert.NoError(t, err)
+	assert.Equal(t, []bilibili.Codecid{bilibili.CodecidAV1, bilibili.CodecidHEVC}, codecs)
+
+	codecs, err = parseCodecs("")
+	assert.NoError(t, err)
+	assert.Empty(t, codecs)
+
+	_, err = parseCodecs("avc,vp9")
+	assert.Error(t, err)
+}
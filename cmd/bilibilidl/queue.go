@@ -0,0 +1,567 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	bilierrors "github.com/misssonder/bilibili/pkg/errors"
+	"github.com/misssonder/bilibili/pkg/video"
+	"github.com/olekukonko/tablewriter"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/vbauerster/mpb/v5"
+	"github.com/vbauerster/mpb/v5/decor"
+)
+
+type jobStatus string
+
+const (
+	jobPending     jobStatus = "pending"
+	jobDownloading jobStatus = "downloading"
+	jobMuxing      jobStatus = "muxing"
+	jobDone        jobStatus = "done"
+	jobFailed      jobStatus = "failed"
+)
+
+const (
+	// queueLockWait is how long a command waits for another one to release the queue file
+	queueLockWait = 10 * time.Second
+	// queueLockStale is the age of a lock left by a crashed command, a save takes far less
+	queueLockStale = 30 * time.Second
+)
+
+var (
+	queueFile    = path.Join(cookieDir, ".bilibili_queue.json")
+	queueFormat  string
+	queueWorkers int
+)
+
+// job is a page or an episode in the queue, File is relative to the output directory of queue run
+type job struct {
+	ID       int           `json:"id"`
+	Season   bool          `json:"season,omitempty"`
+	BvID     string        `json:"bvid"`
+	Cid      int64         `json:"cid"`
+	EpID     int64         `json:"ep_id,omitempty"`
+	Title    string        `json:"title"`
+	Index    int           `json:"index"`
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Aid      int           `json:"aid,omitempty"`
+	Owner    string        `json:"owner,omitempty"`
+	OwnerMid int           `json:"owner_mid,omitempty"`
+	Pubdate  time.Time     `json:"pubdate"`
+	Format   string        `json:"format"`
+	File     string        `json:"file"`
+	Status   jobStatus     `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Attempts int           `json:"attempts"`
+	Updated  time.Time     `json:"updated"`
+}
+
+func (j *job) part() *part {
+	return &part{
+		season:   j.Season,
+		bvID:     j.BvID,
+		cid:      j.Cid,
+		epID:     j.EpID,
+		title:    j.Title,
+		index:    j.Index,
+		name:     j.Name,
+		duration: j.Duration,
+		aid:      j.Aid,
+		owner:    j.Owner,
+		ownerMid: j.OwnerMid,
+		pubdate:  j.Pubdate,
+		file:     j.File,
+		job:      j.ID,
+	}
+}
+
+func (j *job) fnval() bilibili.Fnval {
+	if j.Format == videoFormatMP4 {
+		return bilibili.FnvalMP4
+	}
+	return bilibili.FnvalDash
+}
+
+// queue is the persistent list of jobs, every change is saved to file at once.
+// The file is shared by the commands running at the same time, e.g. queue add during queue run,
+// so every change reloads it under a lock first.
+type queue struct {
+	file string
+	mu   sync.Mutex
+	Jobs []*job `json:"jobs"`
+	Next int    `json:"next"`
+}
+
+func loadQueue(file string) (*queue, error) {
+	q := &queue{file: file, Jobs: make([]*job, 0), Next: 1}
+	if err := q.reload(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// reload replaces the jobs by the ones in file, nothing changes if there is no file yet
+func (q *queue) reload() error {
+	data, err := os.ReadFile(q.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	saved := &queue{Jobs: make([]*job, 0), Next: 1}
+	if err = json.Unmarshal(data, saved); err != nil {
+		return fmt.Errorf("broken queue file %s: %w", q.file, err)
+	}
+	q.Jobs, q.Next = saved.Jobs, saved.Next
+	return nil
+}
+
+// transact reloads the queue under the lock of the file, applies change and saves the queue
+func (q *queue) transact(change func()) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	unlock, err := lockQueueFile(q.file)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = q.reload(); err != nil {
+		return err
+	}
+	change()
+	return q.save()
+}
+
+// lockQueueFile creates the lock file of file and returns the function removing it,
+// a lock older than queueLockStale is left by a crashed command and is taken over
+func lockQueueFile(file string) (func(), error) {
+	lock := file + ".lock"
+	deadline := time.Now().Add(queueLockWait)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > queueLockStale {
+			_ = os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("queue file %s is locked, remove %s if no other queue command is running", file, lock)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// save writes to a temporary file first so an interrupted save does not lose the queue
+func (q *queue) save() error {
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := q.file + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.file)
+}
+
+// add appends the parts as pending jobs and saves the queue, the parts already queued and not done are skipped
+func (q *queue) add(parts []*part, format string) ([]*job, error) {
+	added := make([]*job, 0, len(parts))
+	err := q.transact(func() {
+		for _, p := range parts {
+			if q.queued(p, format) {
+				logrus.Infof("%02d %s is already queued", p.index, p.name)
+				continue
+			}
+			j := &job{
+				ID:       q.Next,
+				Season:   p.season,
+				BvID:     p.bvID,
+				Cid:      p.cid,
+				EpID:     p.epID,
+				Title:    p.title,
+				Index:    p.index,
+				Name:     p.name,
+				Duration: p.duration,
+				Aid:      p.aid,
+				Owner:    p.owner,
+				OwnerMid: p.ownerMid,
+				Pubdate:  p.pubdate,
+				Format:   format,
+				File:     q.uniqueFile(p, p.fileName(len(parts) > 1, "mp4")),
+				Status:   jobPending,
+				Updated:  time.Now(),
+			}
+			q.Next++
+			q.Jobs = append(q.Jobs, j)
+			added = append(added, j)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// uniqueFile returns name, or name with the cid of p if another job writes to name,
+// so the workers never share the partial files
+func (q *queue) uniqueFile(p *part, name string) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for _, file := range []string{name, fmt.Sprintf("%s [%d]%s", base, p.cid, ext)} {
+		if !q.hasFile(file) {
+			return file
+		}
+	}
+	// the same part in another format
+	return fmt.Sprintf("%s [%d] (%d)%s", base, p.cid, q.Next, ext)
+}
+
+func (q *queue) hasFile(file string) bool {
+	for _, j := range q.Jobs {
+		if j.File == file {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *queue) queued(p *part, format string) bool {
+	for _, j := range q.Jobs {
+		if j.BvID == p.bvID && j.Cid == p.cid && j.EpID == p.epID && j.Format == format && j.Status != jobDone {
+			return true
+		}
+	}
+	return false
+}
+
+// pending returns the pending jobs, the jobs left downloading or muxing by an interrupted run are pending again
+func (q *queue) pending() []*job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]*job, 0)
+	for _, j := range q.Jobs {
+		if j.Status == jobDownloading || j.Status == jobMuxing {
+			j.Status = jobPending
+		}
+		if j.Status == jobPending {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs
+}
+
+// retry makes the failed jobs of ids, or all the failed jobs without ids, pending again and saves the queue
+func (q *queue) retry(ids ...int) (int, error) {
+	selected := make(map[int]bool)
+	for _, id := range ids {
+		selected[id] = true
+	}
+	retried := 0
+	err := q.transact(func() {
+		for _, j := range q.Jobs {
+			if j.Status != jobFailed || (len(ids) > 0 && !selected[j.ID]) {
+				continue
+			}
+			j.Status, j.Error, j.Updated = jobPending, "", time.Now()
+			retried++
+		}
+	})
+	return retried, err
+}
+
+// clear removes the done jobs and saves the queue
+func (q *queue) clear() (int, error) {
+	cleared := 0
+	err := q.transact(func() {
+		jobs := make([]*job, 0, len(q.Jobs))
+		for _, j := range q.Jobs {
+			if j.Status != jobDone {
+				jobs = append(jobs, j)
+			}
+		}
+		cleared = len(q.Jobs) - len(jobs)
+		q.Jobs = jobs
+	})
+	return cleared, err
+}
+
+// update sets the status of j and saves the queue, j replaces the job of its id in the reloaded queue
+func (q *queue) update(j *job, status jobStatus, err error) {
+	j.Status, j.Error, j.Updated = status, "", time.Now()
+	switch status {
+	case jobDownloading:
+		j.Attempts++
+	case jobFailed:
+		j.Error = err.Error()
+	}
+	err = q.transact(func() {
+		for i := range q.Jobs {
+			if q.Jobs[i].ID == j.ID {
+				q.Jobs[i] = j
+				return
+			}
+		}
+		q.Jobs = append(q.Jobs, j)
+	})
+	if err != nil {
+		logrus.Warnf("Save queue failed: %v", err)
+	}
+}
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Queue videos and download them later with concurrent workers.",
+}
+
+var queueAddCmd = &cobra.Command{
+	Use:   "add [url/BVID/AVID/ep/ss...]",
+	Short: "Add videos through url/BVID/AVID or episodes through ep/ss links to the queue.",
+	Args:  cobra.ArbitraryArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		switch queueFormat {
+		case videoFormatMP4, videoFormatDash:
+			return nil
+		default:
+			return fmt.Errorf("invalid video format: %s", queueFormat)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		inputs, err := readInputs(args, os.Stdin)
+		exitOnError(err)
+		q, err := loadQueue(queueFile)
+		exitOnError(err)
+		for _, input := range inputs {
+			id, err := video.ExtractBvID(input)
+			exitOnError(err)
+			parts, err := selectParts(id)
+			exitOnError(err)
+			added, err := q.add(parts, queueFormat)
+			exitOnError(err)
+			for _, j := range added {
+				logrus.Infof("Queued #%d %02d %s", j.ID, j.Index, j.Name)
+			}
+		}
+	},
+}
+
+var queueListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the jobs of the queue.",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		q, err := loadQueue(queueFile)
+		exitOnError(err)
+		printJobs(os.Stdout, q.Jobs)
+	},
+}
+
+var queueRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Download the pending jobs of the queue.",
+	Args:  cobra.ExactArgs(0),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkDir(); err != nil {
+			return err
+		}
+		if err := checkSelectOptions(); err != nil {
+			return err
+		}
+		if err := checkOutputTemplate(); err != nil {
+			return err
+		}
+		if err := checkIfExists(); err != nil {
+			return err
+		}
+		if err := openDownloadArchive(); err != nil {
+			return err
+		}
+		return login()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		q, err := loadQueue(queueFile)
+		exitOnError(err)
+		exitOnError(runQueue(q))
+	},
+}
+
+var queueRetryCmd = &cobra.Command{
+	Use:   "retry [id...]",
+	Short: "Make the failed jobs pending again, all of them without ids.",
+	Run: func(cmd *cobra.Command, args []string) {
+		ids := make([]int, 0, len(args))
+		for _, arg := range args {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				exitOnError(fmt.Errorf("invalid job id: %s", arg))
+			}
+			ids = append(ids, id)
+		}
+		q, err := loadQueue(queueFile)
+		exitOnError(err)
+		retried, err := q.retry(ids...)
+		exitOnError(err)
+		logrus.Infof("%d jobs will be retried", retried)
+	},
+}
+
+var queueClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove the done jobs from the queue.",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		q, err := loadQueue(queueFile)
+		exitOnError(err)
+		cleared, err := q.clear()
+		exitOnError(err)
+		logrus.Infof("%d jobs removed", cleared)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(queueCmd)
+	queueCmd.AddCommand(queueAddCmd, queueListCmd, queueRunCmd, queueRetryCmd, queueClearCmd)
+	queueCmd.PersistentFlags().StringVar(&queueFile, "queue-file", queueFile, "The queue file.")
+
+	addInputFlag(queueAddCmd.Flags())
+	queueAddCmd.Flags().StringVar(&queueFormat, "format", videoFormatDash, "The video format (mp4/dash).")
+	queueAddCmd.Flags().BoolVar(&allParts, "all", false, "Queue all pages of the video or all episodes of the season.")
+	queueAddCmd.Flags().StringVar(&partRanges, "pages", "", "The pages or episodes to queue, e.g. 1-5,8.")
+
+	addArchiveFlag(queueRunCmd.Flags())
+	queueRunCmd.Flags().IntVarP(&queueWorkers, "jobs", "j", 2, "How many jobs are downloaded at the same time.")
+	queueRunCmd.Flags().StringVarP(&outputDir, "directory", "d", ".", "The output directory.")
+	queueRunCmd.Flags().IntVarP(&connections, "connections", "n", 4, "How many connections download a file at the same time.")
+	queueRunCmd.Flags().StringVar(&merger, "merger", mergerNative, "How the dash video and audio are merged (native/ffmpeg).")
+	queueRunCmd.Flags().StringVar(&videoQuality, "video-quality", "", "The video quality, e.g. 1080P60 or 4K, a lower one is used if it is not available.")
+	queueRunCmd.Flags().StringVar(&audioQuality, "audio-quality", "", "The audio quality, e.g. 192K or Hi-Res.")
+	queueRunCmd.Flags().StringVar(&videoCodec, "codec", "", "The preferred video codecs of dash in order, e.g. av1,hevc (avc/hevc/av1), AVC is the fallback.")
+}
+
+// runQueue downloads the pending jobs with --jobs workers, the partial files are resumed
+// and the best qualities are used unless they are given as nobody answers prompts
+func runQueue(q *queue) error {
+	jobs := q.pending()
+	if len(jobs) == 0 {
+		logrus.Info("No pending jobs")
+		return nil
+	}
+	assumeBest, continueDownload = true, true
+	workers := queueWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		byID     = make(map[int]*job)
+		progress = mpb.New(mpb.WithWidth(64))
+		total    = progress.AddBar(int64(len(jobs)),
+			mpb.PrependDecorators(
+				decor.Name("Jobs:"),
+				decor.CountersNoUnit(" %d / %d"),
+			),
+			mpb.AppendDecorators(decor.Percentage()),
+		)
+	)
+	for _, j := range jobs {
+		byID[j.ID] = j
+	}
+	sharedProgress = progress
+	onMerge = func(p *part) {
+		if j, ok := byID[p.job]; ok {
+			q.update(j, jobMuxing, nil)
+		}
+	}
+	defer func() {
+		sharedProgress, onMerge = nil, nil
+	}()
+
+	var (
+		queued = make(chan *job)
+		failed int
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queued {
+				q.update(j, jobDownloading, nil)
+				p := j.part()
+				keepSession(false)
+				_, err := downloadPart(p, j.fnval(), outputOf(p, true))
+				if errors.Is(err, bilierrors.ErrNotLogin) {
+					// the session ended during the run
+					keepSession(true)
+					_, err = downloadPart(p, j.fnval(), outputOf(p, true))
+				}
+				if err != nil {
+					q.update(j, jobFailed, err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				} else {
+					q.update(j, jobDone, nil)
+				}
+				total.Increment()
+			}
+		}()
+	}
+	for _, j := range jobs {
+		queued <- j
+	}
+	close(queued)
+	wg.Wait()
+	progress.Wait()
+	printJobs(os.Stdout, jobs)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs failed, run queue retry to download them again", failed, len(jobs))
+	}
+	return nil
+}
+
+func printJobs(w io.Writer, jobs []*job) {
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{
+		"ID",
+		"Status",
+		"Title",
+		"Part",
+		"Format",
+		"Attempts",
+		"Error",
+	})
+	for _, j := range jobs {
+		table.Append([]string{
+			strconv.Itoa(j.ID),
+			string(j.Status),
+			j.Title,
+			fmt.Sprintf("%02d %s", j.Index, j.Name),
+			j.Format,
+			strconv.Itoa(j.Attempts),
+			j.Error,
+		})
+	}
+	table.Render()
+}
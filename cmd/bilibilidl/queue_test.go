@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vbauerster/mpb/v5"
+)
+
+func TestQueue(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "queue.json")
+	q, err := loadQueue(file)
+	assert.NoError(t, err)
+
+	parts := []*part{
+		{bvID: "BV1", cid: 1, title: "video", index: 1, name: "a"},
+		{bvID: "BV1", cid: 2, title: "video", index: 2, name: "b"},
+	}
+	added, err := q.add(parts, videoFormatDash)
+	assert.NoError(t, err)
+	assert.Len(t, added, 2)
+	assert.Equal(t, "video - 01 a.mp4", added[0].File)
+	// queued parts are skipped
+	added2, err := q.add(parts[:1], videoFormatDash)
+	assert.NoError(t, err)
+	assert.Empty(t, added2)
+
+	q.update(added[0], jobDownloading, nil)
+	q.update(added[1], jobDownloading, nil)
+	q.update(added[1], jobFailed, errors.New("403"))
+
+	// reload as an interrupted run leaves the queue
+	q, err = loadQueue(file)
+	assert.NoError(t, err)
+	assert.Equal(t, jobFailed, q.Jobs[1].Status)
+	assert.Equal(t, "403", q.Jobs[1].Error)
+	assert.Equal(t, 1, q.Jobs[1].Attempts)
+
+	pending := q.pending()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].ID)
+
+	retried, err := q.retry(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, retried)
+	retried, err = q.retry()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, retried)
+	assert.Equal(t, jobPending, q.Jobs[1].Status)
+	assert.Empty(t, q.Jobs[1].Error)
+
+	q.update(q.Jobs[0], jobDone, nil)
+	cleared, err := q.clear()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cleared)
+	assert.Len(t, q.Jobs, 1)
+	added, err = q.add(parts[:1], videoFormatDash)
+	assert.NoError(t, err)
+	assert.Len(t, added, 1)
+	assert.Equal(t, 3, added[0].ID)
+}
+
+func TestQueue_UniqueFile(t *testing.T) {
+	q, err := loadQueue(filepath.Join(t.TempDir(), "queue.json"))
+	assert.NoError(t, err)
+
+	first, err := q.add([]*part{{bvID: "BV1", cid: 1, title: "video", index: 1, name: "a"}}, videoFormatDash)
+	assert.NoError(t, err)
+	second, err := q.add([]*part{{bvID: "BV2", cid: 2, title: "video", index: 1, name: "a"}}, videoFormatDash)
+	assert.NoError(t, err)
+	other, err := q.add([]*part{{bvID: "BV2", cid: 2, title: "video", index: 1, name: "a"}}, videoFormatMP4)
+	assert.NoError(t, err)
+	assert.Equal(t, "video.mp4", first[0].File)
+	assert.Equal(t, "video [2].mp4", second[0].File)
+	assert.Equal(t, "video [2] (3).mp4", other[0].File)
+}
+
+func TestQueue_AddDuringRun(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "queue.json")
+	runner, err := loadQueue(file)
+	assert.NoError(t, err)
+	_, err = runner.add([]*part{{bvID: "BV1", cid: 1, title: "a"}}, videoFormatDash)
+	assert.NoError(t, err)
+	running := runner.pending()
+
+	// queue add in another process while the runner works on its jobs
+	adder, err := loadQueue(file)
+	assert.NoError(t, err)
+	added, err := adder.add([]*part{{bvID: "BV2", cid: 2, title: "b"}}, videoFormatDash)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, added[0].ID)
+
+	runner.update(running[0], jobDone, nil)
+	q, err := loadQueue(file)
+	assert.NoError(t, err)
+	assert.Len(t, q.Jobs, 2)
+	assert.Equal(t, jobDone, q.Jobs[0].Status)
+	assert.Equal(t, jobPending, q.Jobs[1].Status)
+	assert.Equal(t, 3, q.Next)
+}
+
+func TestJob_Output(t *testing.T) {
+	defer func(dir string) { outputDir, outputTemplate = dir, "" }(outputDir)
+	file := filepath.Join(t.TempDir(), "queue.json")
+	q, err := loadQueue(file)
+	assert.NoError(t, err)
+	pubdate := time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC)
+	_, err = q.add([]*part{{bvID: "BV1", cid: 1, title: "video", index: 1, name: "a", aid: 2, owner: "uploader", ownerMid: 3, pubdate: pubdate}}, videoFormatDash)
+	assert.NoError(t, err)
+
+	q, err = loadQueue(file)
+	assert.NoError(t, err)
+	p := q.Jobs[0].part()
+	outputDir = "videos"
+	assert.Equal(t, "videos/video.mp4", outputOf(p, true)(videoStream{}))
+	outputTemplate = "{owner}({owner_mid})/{pubdate:2006} {title} {aid}"
+	assert.Equal(t, "videos/uploader(3)/2023 video 2.mp4", outputOf(p, true)(videoStream{}))
+}
+
+func TestLockQueueFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "queue.json")
+	unlock, err := lockQueueFile(file)
+	assert.NoError(t, err)
+	assert.FileExists(t, file+".lock")
+	unlock()
+	assert.NoFileExists(t, file+".lock")
+
+	// a lock left by a crashed command is taken over
+	assert.NoError(t, os.WriteFile(file+".lock", nil, 0644))
+	stale := time.Now().Add(-2 * queueLockStale)
+	assert.NoError(t, os.Chtimes(file+".lock", stale, stale))
+	unlock, err = lockQueueFile(file)
+	assert.NoError(t, err)
+	unlock()
+}
+
+func TestStartMerge_Job(t *testing.T) {
+	defer func(progress *mpb.Progress) { sharedProgress, onMerge = progress, nil }(sharedProgress)
+	sharedProgress = mpb.New()
+	var jobs []int
+	onMerge = func(p *part) {
+		jobs = append(jobs, p.job)
+	}
+	// the renamed file of --if-exists rename is the one merged into
+	file := filepath.Join(t.TempDir(), "video (1).mp4")
+	merging.Store(file, &part{job: 7})
+	defer merging.Delete(file)
+
+	startMerge(file)()
+	startMerge(filepath.Join(t.TempDir(), "video.mp4"))()
+	assert.Equal(t, []int{7}, jobs)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/subtitle"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	subtitleLangs  []string
+	subtitleFormat string
+	embedSubtitles bool
+)
+
+func init() {
+	downloadCmd.Flags().StringSliceVar(&subtitleLangs, "subtitles", nil, "Download subtitles of the languages, e.g. zh-CN,en or ai-zh (all for every language).")
+	downloadCmd.Flags().StringVar(&subtitleFormat, "subtitle-format", string(subtitle.FormatSRT), "The subtitle format (srt/vtt/ass).")
+	downloadCmd.Flags().BoolVar(&embedSubtitles, "embed-subtitles", false, "Mux the subtitles into the video instead of saving them next to it (requires ffmpeg).")
+}
+
+func checkSubtitleFormat() error {
+	_, err := subtitle.ParseFormat(subtitleFormat)
+	return err
+}
+
+// matchSubtitleLang reports whether lan is one of langs, "zh" matches "zh-CN" and "all" matches every language
+func matchSubtitleLang(langs []string, lan string) bool {
+	lan = strings.ToLower(lan)
+	for _, lang := range langs {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "all" || lang == lan || strings.HasPrefix(lan, lang+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// downloadSubtitles saves the selected subtitles next to videoFile as <name>.<lan>.<format>
+func downloadSubtitles(bvID string, cid int64, videoFile string) error {
+	if len(subtitleLangs) == 0 {
+		return nil
+	}
+	format, err := subtitle.ParseFormat(subtitleFormat)
+	if err != nil {
+		return err
+	}
+	if embedSubtitles {
+		format = subtitle.FormatSRT
+	}
+	playerInfo, err := client.PlayerInfo(bvID, cid)
+	if err != nil {
+		return err
+	}
+	var (
+		files []string
+		infos []bilibili.SubtitleInfo
+	)
+	for _, info := range playerInfo.Data.Subtitle.Subtitles {
+		if !matchSubtitleLang(subtitleLangs, info.Lan) || len(info.SubtitleURL) == 0 {
+			continue
+		}
+		body, err := client.SubtitleBody(info.SubtitleURL)
+		if err != nil {
+			return err
+		}
+		file := fmt.Sprintf("%s.%s.%s", strings.TrimSuffix(videoFile, path.Ext(videoFile)), info.Lan, format)
+		if err = writeSubtitle(file, body, format); err != nil {
+			return err
+		}
+		logrus.Infof("Subtitle %s saved to %s", info.LanDoc, file)
+		files = append(files, file)
+		infos = append(infos, info)
+	}
+	if len(files) == 0 {
+		logrus.Warnf("No subtitle matches %s", strings.Join(subtitleLangs, ","))
+		return nil
+	}
+	if embedSubtitles {
+		defer func() {
+			for _, file := range files {
+				_ = os.Remove(file)
+			}
+		}()
+		return muxSubtitles(videoFile, files, infos)
+	}
+	return nil
+}
+
+func writeSubtitle(file string, body *subtitle.Subtitle, format subtitle.Format) error {
+	writer, err := os.OpenFile(file, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	return subtitle.Write(writer, body, format)
+}
+
+// muxSubtitles adds the srt files to videoFile as mov_text streams
+func muxSubtitles(videoFile string, files []string, infos []bilibili.SubtitleInfo) error {
+	if err := checkFFmpeg(); err != nil {
+		return err
+	}
+	tmp := videoFile + ".subtitles" + path.Ext(videoFile)
+	args := []string{"-y", "-i", videoFile}
+	for _, file := range files {
+		args = append(args, "-i", file)
+	}
+	args = append(args, "-map", "0")
+	for i := range files {
+		args = append(args, "-map", fmt.Sprintf("%d", i+1))
+	}
+	args = append(args, "-c", "copy", "-c:s", "mov_text")
+	for i, info := range infos {
+		args = append(args,
+			fmt.Sprintf("-metadata:s:s:%d", i), "language="+info.Lan,
+			fmt.Sprintf("-metadata:s:s:%d", i), "title="+info.LanDoc,
+		)
+	}
+	args = append(args, tmp, "-loglevel", "warning")
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	if err := cmd.Run(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, videoFile)
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchSubtitleLang(t *testing.T) {
+	assert.True(t, matchSubtitleLang([]string{"zh"}, "zh-CN"))
+	assert.True(t, matchSubtitleLang([]string{"en", "ai-zh"}, "ai-zh"))
+	assert.True(t, matchSubtitleLang([]string{"all"}, "ja"))
+	assert.False(t, matchSubtitleLang([]string{"zh"}, "ai-zh"))
+	assert.False(t, matchSubtitleLang(nil, "zh-CN"))
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/misssonder/bilibili/pkg/filename"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ifExistsOverwrite = "overwrite"
+	ifExistsSkip      = "skip"
+	ifExistsRename    = "rename"
+
+	// fileNameBytes leaves room for the suffixes of temporary files like .video.m4s.part.json
+	fileNameBytes = filename.MaxBytes - 32
+)
+
+var (
+	outputTemplate string
+	ifExists       string
+)
+
+// templateField is {name} or {name:format}, the format is a time layout for pubdate
+// or the zero padded width for numbers, e.g. {page:03}
+var templateField = regexp.MustCompile(`\{([a-z_]+)(?::([^}]*))?\}`)
+
+var templateFields = []string{
+	"title", "bvid", "aid", "cid", "page", "part", "owner", "owner_mid", "pubdate",
+	"quality", "codec", "season_title", "ep_index",
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{downloadCmd, queueRunCmd} {
+		cmd.Flags().StringVar(&outputTemplate, "output-template", "",
+			"The output file relative to --directory, e.g. {owner}/{title}/{page:02} {part} [{quality}], "+
+				"the fields are {"+strings.Join(templateFields, "}, {")+"}, .mp4 is appended and directories are created.")
+	}
+	downloadCmd.Flags().StringVar(&ifExists, "if-exists", ifExistsOverwrite, "What to do when the output file exists (overwrite/skip/rename).")
+	queueRunCmd.Flags().StringVar(&ifExists, "if-exists", ifExistsOverwrite, "What to do when the output file exists (overwrite/skip/rename).")
+}
+
+func checkIfExists() error {
+	switch ifExists {
+	case ifExistsOverwrite, ifExistsSkip, ifExistsRename:
+		return nil
+	default:
+		return fmt.Errorf("invalid --if-exists: %s", ifExists)
+	}
+}
+
+// resolveExisting returns the file to write by --if-exists, false means the file exists and is skipped.
+// With skip and rename the returned file is reserved by creating it empty, so the workers downloading
+// at the same time never pick the same name.
+func resolveExisting(file string) (string, bool) {
+	switch ifExists {
+	case ifExistsSkip:
+		if created, err := reserve(file); err == nil && !created {
+			logrus.Infof("%s exists, skipped", file)
+			return file, false
+		}
+		return file, true
+	case ifExistsRename:
+		ext := path.Ext(file)
+		base := strings.TrimSuffix(file, ext)
+		for i := 0; ; i++ {
+			renamed := file
+			if i > 0 {
+				renamed = fmt.Sprintf("%s (%d)%s", base, i, ext)
+			}
+			// other errors are left to the download
+			if created, err := reserve(renamed); err != nil || created {
+				return renamed, true
+			}
+		}
+	default:
+		if _, err := os.Stat(file); err == nil {
+			logrus.Warnf("%s exists and is overwritten", file)
+		}
+		return file, true
+	}
+}
+
+// reserve creates file unless it exists, false is returned if it exists
+func reserve(file string) (bool, error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, f.Close()
+}
+
+func checkOutputTemplate() error {
+	if len(outputTemplate) == 0 {
+		return nil
+	}
+	if len(outputFile) > 0 {
+		return fmt.Errorf("--filename and --output-template can not be used together")
+	}
+	for _, field := range templateField.FindAllStringSubmatch(outputTemplate, -1) {
+		if !isTemplateField(field[1]) {
+			return fmt.Errorf("unknown field {%s} in output template", field[1])
+		}
+	}
+	return nil
+}
+
+func isTemplateField(name string) bool {
+	for _, field := range templateFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// outputFunc returns the output file of a video of the selected stream
+type outputFunc func(s videoStream) string
+
+// outputOf returns the output of p from --output-template, --filename, the name given when p was queued
+// or the default name
+func outputOf(p *part, batch bool) outputFunc {
+	return func(s videoStream) string {
+		var name string
+		switch {
+		case len(outputTemplate) > 0:
+			name = expandTemplate(outputTemplate, p, s)
+		case !batch && len(outputFile) > 0:
+			name = outputFile
+		case len(p.file) > 0:
+			name = p.file
+		default:
+			name = p.fileName(batch, "mp4")
+		}
+		return path.Join(outputDir, name)
+	}
+}
+
+// expandTemplate fills the fields of tmpl by p and s, the slashes of tmpl create directories
+// but the ones of the values do not, every directory and file name is sanitized and
+// the directories left empty by empty fields are dropped, so the name is always relative
+func expandTemplate(tmpl string, p *part, s videoStream) string {
+	name := templateField.ReplaceAllStringFunc(tmpl, func(field string) string {
+		match := templateField.FindStringSubmatch(field)
+		return sanitizeField(p.templateValue(match[1], match[2], s))
+	})
+	if !strings.HasSuffix(strings.ToLower(name), ".mp4") {
+		name += ".mp4"
+	}
+	var (
+		dirs     = strings.Split(name, "/")
+		elements = make([]string, 0, len(dirs))
+	)
+	name, dirs = dirs[len(dirs)-1], dirs[:len(dirs)-1]
+	for _, dir := range dirs {
+		if len(strings.TrimSpace(dir)) > 0 {
+			elements = append(elements, filename.Sanitize(dir, fileNameBytes))
+		}
+	}
+	return strings.Join(append(elements, filename.Sanitize(name, fileNameBytes)), "/")
+}
+
+func (p *part) templateValue(name, format string, s videoStream) string {
+	switch name {
+	case "title":
+		return p.title
+	case "bvid":
+		return p.bvID
+	case "aid":
+		return formatNumber(int64(p.aid), format)
+	case "cid":
+		return formatNumber(p.cid, format)
+	case "page":
+		return formatNumber(int64(p.index), format)
+	case "part":
+		return p.name
+	case "owner":
+		return p.owner
+	case "owner_mid":
+		return formatNumber(int64(p.ownerMid), format)
+	case "pubdate":
+		if p.pubdate.IsZero() {
+			return ""
+		}
+		if len(format) == 0 {
+			format = "2006-01-02"
+		}
+		return p.pubdate.Format(format)
+	case "quality":
+		if s.quality == 0 {
+			return ""
+		}
+		return s.quality.String()
+	case "codec":
+		return s.codec.String()
+	case "season_title":
+		if p.season {
+			return p.title
+		}
+	case "ep_index":
+		if p.season {
+			return formatNumber(int64(p.index), format)
+		}
+	}
+	return ""
+}
+
+// formatNumber pads n with zeros to the width of format, e.g. 03
+func formatNumber(n int64, format string) string {
+	width, err := strconv.Atoi(format)
+	if err != nil || width <= 0 {
+		return strconv.FormatInt(n, 10)
+	}
+	return fmt.Sprintf("%0*d", width, n)
+}
+
+// sanitizeField keeps a value in one path element
+func sanitizeField(value string) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(value)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	bilibili "github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	p := &part{
+		bvID:     "BV1xx411c7mD",
+		cid:      123,
+		title:    "AC/DC live",
+		index:    3,
+		name:     "Back in Black",
+		aid:      456,
+		owner:    "uploader",
+		ownerMid: 789,
+		pubdate:  time.Date(2023, 4, 5, 6, 7, 8, 0, time.Local),
+	}
+	s := videoStream{quality: bilibili.Qn1080P60, codec: bilibili.CodecidHEVC}
+	assert.Equal(t, "uploader(789)/AC_DC live/03 Back in Black [1080P60 HEVC].mp4",
+		expandTemplate("{owner}({owner_mid})/{title}/{page:02} {part} [{quality} {codec}]", p, s))
+	assert.Equal(t, "2023-04 BV1xx411c7mD 456 123.mp4", expandTemplate("{pubdate:2006-01} {bvid} {aid} {cid}.mp4", p, s))
+	// the fields of an unknown stream are empty
+	assert.Equal(t, "Back in Black [].mp4", expandTemplate("{part} [{quality}{codec}]", p, videoStream{}))
+	// only seasons have season fields
+	assert.Equal(t, "- .mp4", expandTemplate("{season_title} - {ep_index}", p, s))
+	// every element is sanitized and can not leave the output directory
+	p.name = "Live: Part 1?"
+	assert.Equal(t, "_/AC_DC live/Live_ Part 1_.mp4", expandTemplate("../{title}/{part}", p, s))
+
+	// empty fields leave no empty directories, so the output stays relative
+	assert.Equal(t, "AC_DC live.mp4", expandTemplate("{season_title}/{title}", p, s))
+	assert.Equal(t, "AC_DC live.mp4", expandTemplate("/{season_title} /{title}", p, s))
+
+	p.season = true
+	assert.Equal(t, "AC_DC live - 3.mp4", expandTemplate("{season_title} - {ep_index}", p, s))
+	assert.Equal(t, "AC_DC live/AC_DC live.mp4", expandTemplate("{season_title}/{title}", p, s))
+}
+
+func TestOutputOf(t *testing.T) {
+	defer func(dir string) { outputDir, outputTemplate = dir, "" }(outputDir)
+	outputDir, outputTemplate = "videos", "{owner}/{title}"
+	p := &part{bvID: "BV1xx411c7mD", title: "video"}
+	assert.Equal(t, "videos/video.mp4", outputOf(p, false)(videoStream{}))
+}
+
+func TestCheckOutputTemplate(t *testing.T) {
+	defer func() { outputTemplate = "" }()
+	outputTemplate = "{title}/{page:02} {part}"
+	assert.NoError(t, checkOutputTemplate())
+	outputTemplate = "{title} {uploader}"
+	assert.Error(t, checkOutputTemplate())
+}
+
+func TestResolveExisting(t *testing.T) {
+	defer func() { ifExists = ifExistsOverwrite }()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "video.mp4")
+
+	ifExists = ifExistsSkip
+	resolved, ok := resolveExisting(file)
+	assert.True(t, ok)
+	assert.Equal(t, file, resolved)
+
+	assert.NoError(t, os.WriteFile(file, nil, 0644))
+	_, ok = resolveExisting(file)
+	assert.False(t, ok)
+
+	ifExists = ifExistsOverwrite
+	resolved, ok = resolveExisting(file)
+	assert.True(t, ok)
+	assert.Equal(t, file, resolved)
+
+	ifExists = ifExistsRename
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "video (1).mp4"), nil, 0644))
+	resolved, ok = resolveExisting(file)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(dir, "video (2).mp4"), resolved)
+	// the name is reserved
+	assert.FileExists(t, resolved)
+	resolved, _ = resolveExisting(file)
+	assert.Equal(t, filepath.Join(dir, "video (3).mp4"), resolved)
+}
+
+func TestResolveExisting_Concurrent(t *testing.T) {
+	defer func() { ifExists = ifExistsOverwrite }()
+	ifExists = ifExistsRename
+	file := filepath.Join(t.TempDir(), "video.mp4")
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		names = make(map[string]bool)
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resolved, ok := resolveExisting(file)
+			assert.True(t, ok)
+			mu.Lock()
+			names[resolved] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, names, 8)
+}
@@ -1,26 +1,161 @@
 package client
 
 import (
+	"context"
 	"io"
 	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL     = "https://api.bilibili.com"
+	defaultPassportURL = "https://passport.bilibili.com"
+	defaultWebURL      = "https://www.bilibili.com"
 )
 
 type Client struct {
 	HttpClient *http.Client
-	cookie     []string
+	jar        *cookieJar
+
+	// baseURL is the scheme and host of the api server, e.g. https://api.bilibili.com
+	baseURL string
+	// passportURL is the scheme and host of the login server, e.g. https://passport.bilibili.com
+	passportURL string
+	// webURL is the scheme and host of the website, e.g. https://www.bilibili.com
+	webURL string
+
+	retryPolicy  RetryPolicy
+	rateLimiter  *RateLimiter
+	hostLimiters map[string]*RateLimiter
+
+	wbi wbiKey
+}
+
+// Option configures a Client created by New
+type Option func(client *Client)
+
+// WithBaseURL overrides the api host (default is https://api.bilibili.com)
+func WithBaseURL(baseURL string) Option {
+	return func(client *Client) {
+		client.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithPassportURL overrides the passport host (default is https://passport.bilibili.com)
+func WithPassportURL(passportURL string) Option {
+	return func(client *Client) {
+		client.passportURL = strings.TrimRight(passportURL, "/")
+	}
+}
+
+// WithWebURL overrides the website host (default is https://www.bilibili.com)
+func WithWebURL(webURL string) Option {
+	return func(client *Client) {
+		client.webURL = strings.TrimRight(webURL, "/")
+	}
+}
+
+// WithHttpClient replaces the underlying http client, a copy of httpClient is used so it is never changed,
+// cookies are stored in the jar of httpClient if it has one
+func WithHttpClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		c := *httpClient
+		client.HttpClient = &c
+	}
+}
+
+// WithTransport sets the http.RoundTripper used by every request, e.g. a proxy transport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(client *Client) {
+		client.httpClient().Transport = transport
+	}
+}
+
+// WithTimeout sets the timeout of every request
+func WithTimeout(timeout time.Duration) Option {
+	return func(client *Client) {
+		client.httpClient().Timeout = timeout
+	}
 }
 
-func (client *Client) newCookieRequest(method, url string, body io.Reader) (*http.Request, error) {
-	request, err := http.NewRequest(method, url, body)
-	if err != nil {
-		return nil, err
+// WithRetryPolicy retries failed requests according to policy, requests are not retried by default
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(client *Client) {
+		client.retryPolicy = policy
 	}
-	for _, c := range client.cookie {
-		request.Header.Add("Cookie", c)
+}
+
+// WithRateLimit shares limiter between requests to every host that has no limiter of its own
+func WithRateLimit(limiter *RateLimiter) Option {
+	return func(client *Client) {
+		client.rateLimiter = limiter
+	}
+}
+
+// WithHostRateLimit limits the requests to host, e.g. api.bilibili.com
+func WithHostRateLimit(host string, limiter *RateLimiter) Option {
+	return func(client *Client) {
+		if client.hostLimiters == nil {
+			client.hostLimiters = make(map[string]*RateLimiter)
+		}
+		client.hostLimiters[host] = limiter
+	}
+}
+
+// New creates a Client, the zero value Client{} is also usable and talks to bilibili directly
+func New(opts ...Option) *Client {
+	client := &Client{}
+	for _, opt := range opts {
+		opt(client)
+	}
+	client.httpClient()
+	return client
+}
+
+// httpClient returns the http client that sends the requests with the cookie jar of client,
+// a HttpClient set by the caller is copied before the jar is attached so it is never changed
+func (client *Client) httpClient() *http.Client {
+	if client.HttpClient == nil {
+		client.HttpClient = &http.Client{}
+	}
+	if jar := client.HttpClient.Jar; jar == nil || jar != http.CookieJar(client.jar) {
+		httpClient := *client.HttpClient
+		httpClient.Jar = client.wrapJar(jar)
+		client.HttpClient = &httpClient
+	}
+	return client.HttpClient
+}
+
+func (client *Client) limiter(host string) *RateLimiter {
+	if limiter, ok := client.hostLimiters[host]; ok {
+		return limiter
+	}
+	return client.rateLimiter
+}
+
+func (client *Client) apiURL(path string) string {
+	if len(client.baseURL) == 0 {
+		return defaultBaseURL + path
+	}
+	return client.baseURL + path
+}
+
+func (client *Client) passportAPIURL(path string) string {
+	if len(client.passportURL) == 0 {
+		return defaultPassportURL + path
+	}
+	return client.passportURL + path
+}
+
+func (client *Client) webPageURL(path string) string {
+	if len(client.webURL) == 0 {
+		return defaultWebURL + path
 	}
-	return request, nil
+	return client.webURL + path
 }
 
-func (client *Client) SetCookie(cookie []string) {
-	client.cookie = cookie
+// newRequest creates a request, cookies are attached by the jar of the http client
+func (client *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, method, url, body)
 }
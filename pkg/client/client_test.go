@@ -0,0 +1,52 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingTransport struct {
+	count int
+}
+
+func (t *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.count++
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+func TestNew(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, _ = w.Write([]byte(`{"code":0,"message":"0","data":{"bvid":"BV117411r7R1","url":"u","qrcode_key":"k"}}`))
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	client := New(
+		WithBaseURL(server.URL+"/"),
+		WithPassportURL(server.URL),
+		WithTransport(transport),
+		WithTimeout(time.Second),
+	)
+	info, err := client.GetVideoInfo("BV117411r7R1")
+	assert.NoError(t, err)
+	assert.Equal(t, "BV117411r7R1", info.Data.Bvid)
+	qrcode, err := client.GenerateQrcode()
+	assert.NoError(t, err)
+	assert.Equal(t, "k", qrcode.Data.QrcodeKey)
+
+	assert.Equal(t, []string{videoInfoPath, generateQrCodePath}, paths)
+	assert.Equal(t, 2, transport.count)
+	assert.Equal(t, time.Second, client.HttpClient.Timeout)
+}
+
+func TestClient_defaultURL(t *testing.T) {
+	client := &Client{}
+	assert.Equal(t, defaultBaseURL+navInfoPath, client.apiURL(navInfoPath))
+	assert.Equal(t, defaultPassportURL+pollQrCodePath, client.passportAPIURL(pollQrCodePath))
+}
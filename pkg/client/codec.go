@@ -0,0 +1,128 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Codecid is the video codec of a dash stream
+type Codecid int
+
+const (
+	CodecidAVC  Codecid = 7
+	CodecidHEVC Codecid = 12
+	CodecidAV1  Codecid = 13
+)
+
+// DefaultCodecOrder is the fallback when none of the preferred codecs is available,
+// AVC first because every device can play it
+var DefaultCodecOrder = []Codecid{CodecidAVC, CodecidHEVC, CodecidAV1}
+
+func (codecid Codecid) String() string {
+	switch codecid {
+	case CodecidAVC:
+		return "AVC"
+	case CodecidHEVC:
+		return "HEVC"
+	case CodecidAV1:
+		return "AV1"
+	default:
+		return ""
+	}
+}
+
+// ParseCodec parses avc (h264), hevc (h265) or av1
+func ParseCodec(s string) (Codecid, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "avc", "h264", "h.264", "avc1":
+		return CodecidAVC, nil
+	case "hevc", "h265", "h.265", "hev1":
+		return CodecidHEVC, nil
+	case "av1", "av01":
+		return CodecidAV1, nil
+	default:
+		return 0, fmt.Errorf("invalid codec: %s", s)
+	}
+}
+
+// VideoQualities returns the distinct qualities of the video streams in their order
+func (dash *Dash) VideoQualities() []Qn {
+	qns := make([]Qn, 0, len(dash.Video))
+	seen := make(map[Qn]bool)
+	for _, video := range dash.Video {
+		if qn := Qn(video.ID); !seen[qn] {
+			seen[qn] = true
+			qns = append(qns, qn)
+		}
+	}
+	return qns
+}
+
+// VideoCodecs returns the codecs available for the video of qn
+func (dash *Dash) VideoCodecs(qn Qn) []Codecid {
+	codecs := make([]Codecid, 0)
+	for _, video := range dash.Video {
+		if Qn(video.ID) == qn {
+			codecs = append(codecs, video.Codecid)
+		}
+	}
+	return codecs
+}
+
+// SelectVideo returns the video stream of qn whose codec comes first in prefer, then in DefaultCodecOrder.
+// False is returned if there is no video of qn.
+func (dash *Dash) SelectVideo(qn Qn, prefer ...Codecid) (*DashMedia, bool) {
+	var found *DashMedia
+	for _, codec := range append(append([]Codecid{}, prefer...), DefaultCodecOrder...) {
+		for i := range dash.Video {
+			if Qn(dash.Video[i].ID) != qn {
+				continue
+			}
+			if dash.Video[i].Codecid == codec {
+				return &dash.Video[i], true
+			}
+			if found == nil {
+				found = &dash.Video[i]
+			}
+		}
+	}
+	return found, found != nil
+}
+
+// Audios returns the audio streams including Dolby Atmos and Hi-Res
+func (dash *Dash) Audios() []*DashMedia {
+	audios := make([]*DashMedia, 0, len(dash.Audio)+len(dash.Dolby.Audio)+1)
+	for i := range dash.Audio {
+		audios = append(audios, &dash.Audio[i])
+	}
+	for i := range dash.Dolby.Audio {
+		audios = append(audios, &dash.Dolby.Audio[i])
+	}
+	if dash.Flac != nil && dash.Flac.Audio != nil {
+		audios = append(audios, dash.Flac.Audio)
+	}
+	return audios
+}
+
+// AudioQualities returns the distinct qualities of Audios
+func (dash *Dash) AudioQualities() []Qn {
+	qns := make([]Qn, 0)
+	seen := make(map[Qn]bool)
+	for _, audio := range dash.Audios() {
+		if qn := Qn(audio.ID); !seen[qn] {
+			seen[qn] = true
+			qns = append(qns, qn)
+		}
+	}
+	return qns
+}
+
+// SelectAudio returns the audio stream of qn, QnAudioDolby and QnAudioHiRes included
+func (dash *Dash) SelectAudio(qn Qn) (*DashMedia, bool) {
+	for _, audio := range dash.Audios() {
+		if Qn(audio.ID) == qn {
+			return audio, true
+		}
+	}
+	return nil, false
+}
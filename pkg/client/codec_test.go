@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCodec(t *testing.T) {
+	codec, err := ParseCodec("H265")
+	assert.NoError(t, err)
+	assert.Equal(t, CodecidHEVC, codec)
+
+	codec, err = ParseCodec(" av1 ")
+	assert.NoError(t, err)
+	assert.Equal(t, CodecidAV1, codec)
+
+	_, err = ParseCodec("vp9")
+	assert.Error(t, err)
+}
+
+func TestDash_SelectVideo(t *testing.T) {
+	dash := &Dash{
+		Video: []DashMedia{
+			{ID: int(Qn4k), Codecid: CodecidHEVC},
+			{ID: int(Qn1080P), Codecid: CodecidHEVC},
+			{ID: int(Qn1080P), Codecid: CodecidAVC},
+			{ID: int(Qn1080P), Codecid: CodecidAV1},
+		},
+		Audio: []DashMedia{{ID: int(QnAudio192K)}},
+	}
+	assert.Equal(t, []Qn{Qn4k, Qn1080P}, dash.VideoQualities())
+	assert.Equal(t, []Codecid{CodecidHEVC, CodecidAVC, CodecidAV1}, dash.VideoCodecs(Qn1080P))
+
+	video, ok := dash.SelectVideo(Qn1080P, CodecidAV1, CodecidHEVC)
+	assert.True(t, ok)
+	assert.Equal(t, CodecidAV1, video.Codecid)
+
+	// without a preference AVC is picked first
+	video, ok = dash.SelectVideo(Qn1080P)
+	assert.True(t, ok)
+	assert.Equal(t, CodecidAVC, video.Codecid)
+
+	// 4K only has HEVC
+	video, ok = dash.SelectVideo(Qn4k, CodecidAV1)
+	assert.True(t, ok)
+	assert.Equal(t, CodecidHEVC, video.Codecid)
+
+	_, ok = dash.SelectVideo(Qn720P)
+	assert.False(t, ok)
+
+	audio, ok := dash.SelectAudio(QnAudio192K)
+	assert.True(t, ok)
+	assert.Equal(t, int(QnAudio192K), audio.ID)
+}
+
+func TestDash_SelectAudio(t *testing.T) {
+	data := `{
+		"audio": [{"id": 30280, "codecid": 0}, {"id": 30216, "codecid": 0}],
+		"dolby": {"type": 1, "audio": [{"id": 30250, "base_url": "https://dolby"}]},
+		"flac": {"display": true, "audio": {"id": 30251, "base_url": "https://flac"}}
+	}`
+	dash := &Dash{}
+	assert.NoError(t, json.Unmarshal([]byte(data), dash))
+	assert.Equal(t, []Qn{QnAudio192K, QnAudio64K, QnAudioDolby, QnAudioHiRes}, dash.AudioQualities())
+
+	audio, ok := dash.SelectAudio(QnAudioDolby)
+	assert.True(t, ok)
+	assert.Equal(t, "https://dolby", audio.BaseURL)
+
+	audio, ok = dash.SelectAudio(QnAudioHiRes)
+	assert.True(t, ok)
+	assert.Equal(t, "https://flac", audio.BaseURL)
+
+	dash = &Dash{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"audio": [{"id": 30280}], "dolby": {"type": 0, "audio": null}, "flac": null}`), dash))
+	assert.Equal(t, []Qn{QnAudio192K}, dash.AudioQualities())
+	_, ok = dash.SelectAudio(QnAudioHiRes)
+	assert.False(t, ok)
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cookie names set by bilibili on login
+const (
+	CookieSessData   = "SESSDATA"
+	CookieBiliJct    = "bili_jct"
+	CookieDedeUserID = "DedeUserID"
+	CookieBuvid3     = "buvid3"
+)
+
+// cookieJar is a http.CookieJar that also remembers the attributes of the cookies it was given,
+// cookiejar.Jar only hands back name and value so the expiry would be lost
+type cookieJar struct {
+	jar http.CookieJar
+
+	mu      sync.Mutex
+	cookies map[string]*http.Cookie
+}
+
+// newCookieJar wraps jar, a new cookiejar.Jar is used if jar is nil
+func newCookieJar(jar http.CookieJar) *cookieJar {
+	if jar == nil {
+		// cookiejar.New never returns an error
+		jar, _ = cookiejar.New(nil)
+	}
+	return &cookieJar{
+		jar:     jar,
+		cookies: make(map[string]*http.Cookie),
+	}
+}
+
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, cookie := range cookies {
+		if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+			delete(j.cookies, cookie.Name)
+			continue
+		}
+		c := *cookie
+		if c.MaxAge > 0 {
+			c.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+		j.cookies[c.Name] = &c
+	}
+}
+
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+func (j *cookieJar) get(name string) (*http.Cookie, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	cookie, ok := j.cookies[name]
+	if !ok || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+		return nil, false
+	}
+	c := *cookie
+	return &c, true
+}
+
+func (j *cookieJar) all() []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	cookies := make([]*http.Cookie, 0, len(j.cookies))
+	for _, cookie := range j.cookies {
+		if !cookie.Expires.IsZero() && cookie.Expires.Before(time.Now()) {
+			continue
+		}
+		c := *cookie
+		cookies = append(cookies, &c)
+	}
+	return cookies
+}
+
+// cookieJar returns the jar the http client sends cookies from
+func (client *Client) cookieJar() *cookieJar {
+	client.httpClient()
+	return client.jar
+}
+
+// wrapJar returns the jar of client that stores cookies in jar, the cookies of client are kept if jar is nil
+func (client *Client) wrapJar(jar http.CookieJar) *cookieJar {
+	if client.jar == nil || jar != nil {
+		client.jar = newCookieJar(jar)
+	}
+	return client.jar
+}
+
+// cookieURLs are the hosts a cookie without domain is stored for
+func (client *Client) cookieURLs() []*url.URL {
+	urls := make([]*url.URL, 0, 3)
+	for _, raw := range []string{client.apiURL("/"), client.passportAPIURL("/"), client.webPageURL("/")} {
+		if u, err := url.Parse(raw); err == nil {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// SetCookie stores cookies in the format of Set-Cookie header lines, e.g. LoginResp.Cookie
+func (client *Client) SetCookie(cookie []string) {
+	header := http.Header{}
+	for _, c := range cookie {
+		if len(c) != 0 {
+			header.Add("Set-Cookie", c)
+		}
+	}
+	client.SetCookies((&http.Response{Header: header}).Cookies())
+}
+
+// SetCookies stores cookies for every bilibili host the client talks to
+func (client *Client) SetCookies(cookies []*http.Cookie) {
+	jar := client.cookieJar()
+	for _, u := range client.cookieURLs() {
+		jar.SetCookies(u, cookies)
+	}
+}
+
+// Cookies returns the unexpired cookies with their attributes, String() of each one is a valid Set-Cookie line
+func (client *Client) Cookies() []*http.Cookie {
+	return client.cookieJar().all()
+}
+
+// Cookie returns the unexpired cookie named name
+func (client *Client) Cookie(name string) (*http.Cookie, bool) {
+	return client.cookieJar().get(name)
+}
+
+// CSRF returns the csrf token (bili_jct) required by write apis
+func (client *Client) CSRF() string {
+	if cookie, ok := client.Cookie(CookieBiliJct); ok {
+		return cookie.Value
+	}
+	return ""
+}
+
+// Mid returns the id of the logged-in user (DedeUserID)
+func (client *Client) Mid() int64 {
+	if cookie, ok := client.Cookie(CookieDedeUserID); ok {
+		mid, _ := strconv.ParseInt(cookie.Value, 10, 64)
+		return mid
+	}
+	return 0
+}
+
+// CookieExpires returns when SESSDATA expires, zero if there is no SESSDATA or it is a session cookie
+func (client *Client) CookieExpires() time.Time {
+	if cookie, ok := client.Cookie(CookieSessData); ok {
+		return cookie.Expires
+	}
+	return time.Time{}
+}
+
+// CookieExpired reports whether there is no valid SESSDATA
+func (client *Client) CookieExpired() bool {
+	_, ok := client.Cookie(CookieSessData)
+	return !ok
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_SetCookie(t *testing.T) {
+	var cookieHeader []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookieHeader = r.Header.Values("Cookie")
+		http.SetCookie(w, &http.Cookie{Name: CookieBuvid3, Value: "buvid", Path: "/"})
+		_, _ = w.Write([]byte(`{"code":0,"data":{"isLogin":true}}`))
+	}))
+	defer server.Close()
+
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	client := New(WithBaseURL(server.URL))
+	client.SetCookie([]string{
+		"SESSDATA=sess; Path=/; Expires=" + expires.Format(http.TimeFormat) + "; HttpOnly",
+		"bili_jct=csrf; Path=/; Expires=" + expires.Format(http.TimeFormat),
+		"DedeUserID=35988173; Path=/",
+		"",
+	})
+	assert.Equal(t, "csrf", client.CSRF())
+	assert.Equal(t, int64(35988173), client.Mid())
+	assert.Equal(t, expires, client.CookieExpires())
+	assert.False(t, client.CookieExpired())
+
+	_, err := client.NavInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"SESSDATA=sess; bili_jct=csrf; DedeUserID=35988173"}, cookieHeader)
+
+	var names []string
+	for _, cookie := range client.Cookies() {
+		names = append(names, cookie.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{CookieDedeUserID, CookieSessData, CookieBiliJct, CookieBuvid3}, names)
+
+	client.SetCookie([]string{"SESSDATA=sess; Path=/; Max-Age=-1"})
+	assert.True(t, client.CookieExpired())
+	assert.True(t, client.CookieExpires().IsZero())
+}
+
+func TestClient_HttpClientJar(t *testing.T) {
+	var cookieHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookieHeader = r.Header.Get("Cookie")
+		_, _ = w.Write([]byte(`{"code":0,"data":{"isLogin":true}}`))
+	}))
+	defer server.Close()
+
+	jar, err := cookiejar.New(nil)
+	assert.NoError(t, err)
+	httpClient := &http.Client{Jar: jar}
+	client := New(WithBaseURL(server.URL), WithHttpClient(httpClient), WithTimeout(time.Second))
+	client.SetCookie([]string{"SESSDATA=sess; Path=/"})
+	assert.Equal(t, time.Duration(0), httpClient.Timeout)
+
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, []*http.Cookie{{Name: CookieSessData, Value: "sess"}}, jar.Cookies(u))
+	_, err = client.NavInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "SESSDATA=sess", cookieHeader)
+	assert.False(t, client.CookieExpired())
+}
+
+func TestClient_DefaultClient(t *testing.T) {
+	client := New(WithHttpClient(http.DefaultClient), WithTimeout(time.Second), WithTransport(&countingTransport{}))
+	client.SetCookie([]string{"SESSDATA=sess; Path=/"})
+	assert.Nil(t, http.DefaultClient.Jar)
+	assert.Nil(t, http.DefaultClient.Transport)
+	assert.Equal(t, time.Duration(0), http.DefaultClient.Timeout)
+	assert.NotSame(t, http.DefaultClient, client.HttpClient)
+}
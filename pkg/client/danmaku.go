@@ -0,0 +1,106 @@
+package client
+
+import (
+	"compress/flate"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/danmaku"
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+const (
+	danmakuXMLPath     = "/x/v1/dm/list.so"
+	danmakuSegmentPath = "/x/v2/dm/web/seg.so"
+	// danmakuSegmentDuration is the length of video covered by one segment
+	danmakuSegmentDuration = 6 * time.Minute
+)
+
+// DanmakuXML https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/docs/danmaku/danmaku_xml.md
+// fetches the legacy xml list of cid, which only keeps a limited number of recent danmakus
+func (client *Client) DanmakuXML(cid int64) ([]danmaku.Danmaku, error) {
+	return client.DanmakuXMLWithContext(context.Background(), cid)
+}
+
+func (client *Client) DanmakuXMLWithContext(ctx context.Context, cid int64) ([]danmaku.Danmaku, error) {
+	url := fmt.Sprintf("%s?oid=%d", client.apiURL(danmakuXMLPath), cid)
+	request, err := client.newRequest(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	var danmakus []danmaku.Danmaku
+	if _, err = client.send(request, func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusOK {
+			return errors.ErrUnexpectedStatusCode(resp.StatusCode)
+		}
+		// the body is raw deflate which net/http does not decode
+		var body io.Reader = resp.Body
+		if resp.Header.Get("Content-Encoding") == "deflate" {
+			reader := flate.NewReader(resp.Body)
+			defer reader.Close()
+			body = reader
+		}
+		danmakus, err = danmaku.ParseXML(body)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return danmakus, nil
+}
+
+// DanmakuSegment https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/docs/danmaku/danmaku_proto.md
+// fetches the protobuf segment of cid, segment index starts from 1 and every segment covers 6 minutes
+func (client *Client) DanmakuSegment(cid int64, index int) ([]danmaku.Danmaku, error) {
+	return client.DanmakuSegmentWithContext(context.Background(), cid, index)
+}
+
+func (client *Client) DanmakuSegmentWithContext(ctx context.Context, cid int64, index int) ([]danmaku.Danmaku, error) {
+	url := fmt.Sprintf("%s?type=1&oid=%d&segment_index=%d", client.apiURL(danmakuSegmentPath), cid, index)
+	request, err := client.newRequest(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	var danmakus []danmaku.Danmaku
+	if _, err = client.send(request, func(resp *http.Response) error {
+		// errors are returned as json instead of protobuf
+		if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+			_, err := decodeResponse(resp, nil)
+			return err
+		}
+		var body []byte
+		if err := readBody(&body)(resp); err != nil {
+			return err
+		}
+		danmakus, err = danmaku.ParseSegment(body)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return danmakus, nil
+}
+
+// Danmakus fetches every segment of a video lasting duration
+func (client *Client) Danmakus(cid int64, duration time.Duration) ([]danmaku.Danmaku, error) {
+	return client.DanmakusWithContext(context.Background(), cid, duration)
+}
+
+func (client *Client) DanmakusWithContext(ctx context.Context, cid int64, duration time.Duration) ([]danmaku.Danmaku, error) {
+	segments := int((duration + danmakuSegmentDuration - 1) / danmakuSegmentDuration)
+	if segments < 1 {
+		segments = 1
+	}
+	danmakus := make([]danmaku.Danmaku, 0)
+	for i := 1; i <= segments; i++ {
+		segment, err := client.DanmakuSegmentWithContext(ctx, cid, i)
+		if err != nil {
+			return nil, err
+		}
+		danmakus = append(danmakus, segment...)
+	}
+	danmaku.Sort(danmakus)
+	return danmakus, nil
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"compress/flate"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_DanmakuXML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1176840", r.URL.Query().Get("oid"))
+		w.Header().Set("Content-Encoding", "deflate")
+		writer, _ := flate.NewWriter(w, flate.DefaultCompression)
+		_, _ = writer.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><i><chatid>1176840</chatid>` +
+			`<d p="1.5,1,25,16777215,1665460317,0,aaaa,1,5">弹幕</d></i>`))
+		_ = writer.Close()
+	}))
+	defer server.Close()
+
+	danmakus, err := New(WithBaseURL(server.URL)).DanmakuXML(1176840)
+	assert.NoError(t, err)
+	assert.Len(t, danmakus, 1)
+	assert.Equal(t, "弹幕", danmakus[0].Content)
+}
+
+func TestClient_Danmakus(t *testing.T) {
+	var segments []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index := r.URL.Query().Get("segment_index")
+		segments = append(segments, index)
+		if index == "3" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"code":-404,"message":"啥都木有"}`))
+			return
+		}
+		// DmSegMobileReply{elems: [{progress: index, content: "x"}]}
+		_, _ = w.Write([]byte{0x0a, 0x05, 0x10, index[0] - '0', 0x3a, 0x01, 'x'})
+	}))
+	defer server.Close()
+
+	client := New(WithBaseURL(server.URL))
+	danmakus, err := client.Danmakus(1, 12*time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, segments)
+	assert.Len(t, danmakus, 2)
+	assert.Equal(t, time.Millisecond, danmakus[0].Progress)
+
+	_, err = client.DanmakuSegment(1, 3)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
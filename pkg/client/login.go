@@ -1,20 +1,18 @@
 package client
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 
-	"github.com/misssonder/bilibili/pkg/errors"
 	"github.com/misssonder/bilibili/pkg/qrcode"
 )
 
 const (
-	generateQrCodeUrl = "https://passport.bilibili.com/x/passport-login/web/qrcode/generate"
-	pollQrCodeUrl     = "https://passport.bilibili.com/x/passport-login/web/qrcode/poll"
-	navInfoUrl        = "https://api.bilibili.com/x/web-interface/nav"
+	generateQrCodePath = "/x/passport-login/web/qrcode/generate"
+	pollQrCodePath     = "/x/passport-login/web/qrcode/poll"
+	navInfoPath        = "/x/web-interface/nav"
 )
 
 type GenerateQrCodeResp struct {
@@ -149,95 +147,54 @@ type NavInfoResp struct {
 
 // GenerateQrcode https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/login/login_action/QR.md
 func (client *Client) GenerateQrcode() (*GenerateQrCodeResp, error) {
-	client.HttpClient = &http.Client{}
-	resp, err := client.HttpClient.Get(generateQrCodeUrl)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
-	}
+	return client.GenerateQrcodeWithContext(context.Background())
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+func (client *Client) GenerateQrcodeWithContext(ctx context.Context) (*GenerateQrCodeResp, error) {
+	request, err := client.newRequest(ctx, http.MethodGet, client.passportAPIURL(generateQrCodePath), nil)
 	if err != nil {
 		return nil, err
 	}
-
 	generateQrCodeResp := &GenerateQrCodeResp{}
-	if err = json.Unmarshal(body, generateQrCodeResp); err != nil {
+	if _, err = client.do(request, generateQrCodeResp); err != nil {
 		return nil, err
 	}
-
-	if generateQrCodeResp.Code != 0 {
-		return nil, errors.StatusError{Code: generateQrCodeResp.Code, Cause: generateQrCodeResp.Message}
-	}
 	return generateQrCodeResp, nil
 }
 
 // PollQrcode https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/login/login_action/QR.md
 func (client *Client) PollQrcode(qrcode string) (*PollQrCodeResp, http.Header, error) {
-	client.HttpClient = &http.Client{}
-
-	url := fmt.Sprintf("%s?qrcode_key=%s", pollQrCodeUrl, qrcode)
-	resp, err := client.HttpClient.Get(url)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
-	}
+	return client.PollQrcodeWithContext(context.Background(), qrcode)
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+func (client *Client) PollQrcodeWithContext(ctx context.Context, qrcode string) (*PollQrCodeResp, http.Header, error) {
+	url := fmt.Sprintf("%s?qrcode_key=%s", client.passportAPIURL(pollQrCodePath), qrcode)
+	request, err := client.newRequest(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, nil, err
 	}
-
 	pollQrCodeResp := &PollQrCodeResp{}
-	if err = json.Unmarshal(body, pollQrCodeResp); err != nil {
+	header, err := client.do(request, pollQrCodeResp)
+	if err != nil {
 		return nil, nil, err
 	}
-
-	if pollQrCodeResp.Code != 0 {
-		return nil, nil, errors.StatusError{Code: pollQrCodeResp.Code, Cause: pollQrCodeResp.Message}
-	}
-	return pollQrCodeResp, resp.Header, nil
+	return pollQrCodeResp, header, nil
 }
 
 func (client *Client) NavInfo() (*NavInfoResp, error) {
-	client.HttpClient = &http.Client{}
-	request, err := client.newCookieRequest(http.MethodGet, navInfoUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := client.HttpClient.Do(request)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
-	}
+	return client.NavInfoWithContext(context.Background())
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+func (client *Client) NavInfoWithContext(ctx context.Context) (*NavInfoResp, error) {
+	request, err := client.newRequest(ctx, http.MethodGet, client.apiURL(navInfoPath), nil)
 	if err != nil {
 		return nil, err
 	}
 
 	navInfoResp := &NavInfoResp{}
-	if err = json.Unmarshal(body, navInfoResp); err != nil {
+	if _, err = client.do(request, navInfoResp); err != nil {
 		return nil, err
 	}
-
-	if navInfoResp.Code != 0 {
-		return nil, errors.StatusError{Code: navInfoResp.Code, Cause: navInfoResp.Message}
-	}
-
 	return navInfoResp, nil
 }
 
@@ -246,6 +203,8 @@ type LoginStatus int
 type LoginResp struct {
 	LoginStatus LoginStatus
 	Cookie      []string
+	// RefreshToken is used to refresh the cookie later, see RefreshCookies
+	RefreshToken string
 }
 
 var (
@@ -257,7 +216,12 @@ var (
 
 // LoginWithQrCode writer is where the qrcode be written
 func (client *Client) LoginWithQrCode(writer io.Writer) (<-chan LoginResp, error) {
-	generateQrCodeResp, err := client.GenerateQrcode()
+	return client.LoginWithQrCodeWithContext(context.Background(), writer)
+}
+
+// LoginWithQrCodeWithContext is like LoginWithQrCode, polling stops and the channel is closed when ctx is done
+func (client *Client) LoginWithQrCodeWithContext(ctx context.Context, writer io.Writer) (<-chan LoginResp, error) {
+	generateQrCodeResp, err := client.GenerateQrcodeWithContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -273,18 +237,31 @@ func (client *Client) LoginWithQrCode(writer io.Writer) (<-chan LoginResp, error
 			pollQrCodeResp *PollQrCodeResp
 			respHeader     http.Header
 		)
+		var send = func(resp LoginResp) bool {
+			select {
+			case loginResp <- resp:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
 		for {
-			pollQrCodeResp, respHeader, err = client.PollQrcode(generateQrCodeResp.Data.QrcodeKey)
+			pollQrCodeResp, respHeader, err = client.PollQrcodeWithContext(ctx, generateQrCodeResp.Data.QrcodeKey)
 			if err != nil {
-				loginResp <- LoginResp{
-					LoginStatus: LoginStatus(-1),
-					Cookie:      nil,
+				if ctx.Err() == nil {
+					send(LoginResp{
+						LoginStatus: LoginStatus(-1),
+						Cookie:      nil,
+					})
 				}
 				return
 			}
-			loginResp <- LoginResp{
-				LoginStatus: LoginStatus(pollQrCodeResp.Data.Code),
-				Cookie:      respHeader.Values("Set-Cookie"),
+			if !send(LoginResp{
+				LoginStatus:  LoginStatus(pollQrCodeResp.Data.Code),
+				Cookie:       respHeader.Values("Set-Cookie"),
+				RefreshToken: pollQrCodeResp.Data.RefreshToken,
+			}) {
+				return
 			}
 			switch pollQrCodeResp.Data.Code {
 			case int(LoginSuccess), int(LoginExpired):
@@ -1,10 +1,16 @@
 package client
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/misssonder/bilibili/internal/util"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestLogin(t *testing.T) {
@@ -41,3 +47,42 @@ func TestClient_NavInfo(t *testing.T) {
 	}
 	t.Log(util.MustMarshal(info))
 }
+
+func TestClient_LoginWithQrCodeWithContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case generateQrCodePath:
+			_, _ = w.Write([]byte(`{"code":0,"data":{"url":"https://example.com","qrcode_key":"key"}}`))
+		case pollQrCodePath:
+			_, _ = w.Write([]byte(`{"code":0,"data":{"code":86101}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := New(WithPassportURL(server.URL))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resps, err := client.LoginWithQrCodeWithContext(ctx, io.Discard)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp := <-resps
+	assert.Equal(t, LoginNotScan, resp.LoginStatus)
+	cancel()
+	select {
+	case <-drain(resps):
+	case <-time.After(time.Second):
+		t.Error("polling did not stop after cancel")
+	}
+}
+
+func drain(resps <-chan LoginResp) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range resps {
+		}
+	}()
+	return done
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Qn https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/video/videostream_url.md#qn%E8%A7%86%E9%A2%91%E6%B8%85%E6%99%B0%E5%BA%A6%E6%A0%87%E8%AF%86
+type Qn int64
+
+const (
+	Qn240P        Qn = 6
+	Qn360P        Qn = 16
+	Qn480P        Qn = 32
+	Qn720P        Qn = 64
+	Qn720P60      Qn = 74
+	Qn1080P       Qn = 80
+	Qn1080PPlus   Qn = 112
+	Qn1080P60     Qn = 116
+	Qn4k          Qn = 120
+	QnHDR         Qn = 125
+	QnDolbyVision Qn = 126
+	Qn8K          Qn = 127
+
+	QnAudio64K   Qn = 30216
+	QnAudio132K  Qn = 30232
+	QnAudio192K  Qn = 30280
+	QnAudioDolby Qn = 30250
+	QnAudioHiRes Qn = 30251
+)
+
+// Quality describes a quality level
+type Quality struct {
+	Qn    Qn
+	Label string
+	// Aliases are the other names accepted by ParseQn
+	Aliases []string
+	// Fnval is the flags the request needs to get this quality, 0 means any format
+	Fnval     Fnval
+	NeedLogin bool
+	NeedVip   bool
+	Audio     bool
+}
+
+// qualities are ordered from the worst to the best, videos first
+var qualities = []Quality{
+	{Qn: Qn240P, Label: "240P"},
+	{Qn: Qn360P, Label: "360P"},
+	{Qn: Qn480P, Label: "480P"},
+	{Qn: Qn720P, Label: "720P", NeedLogin: true},
+	{Qn: Qn720P60, Label: "720P60", Fnval: FnvalDash, NeedLogin: true},
+	{Qn: Qn1080P, Label: "1080P", NeedLogin: true},
+	{Qn: Qn1080PPlus, Label: "1080P+", Aliases: []string{"1080PPlus", "1080P高码率"}, NeedLogin: true, NeedVip: true},
+	{Qn: Qn1080P60, Label: "1080P60", Fnval: FnvalDash, NeedLogin: true, NeedVip: true},
+	{Qn: Qn4k, Label: "4K", Aliases: []string{"2160P"}, Fnval: FnvalDash | Fnval4K, NeedLogin: true, NeedVip: true},
+	{Qn: QnHDR, Label: "HDR", Fnval: FnvalDash | FnvalHDR, NeedLogin: true, NeedVip: true},
+	{Qn: QnDolbyVision, Label: "Dolby-Vision", Aliases: []string{"DV"}, Fnval: FnvalDash | FnvalDolbyVision, NeedLogin: true, NeedVip: true},
+	{Qn: Qn8K, Label: "8K", Aliases: []string{"4320P"}, Fnval: FnvalDash | Fnval8K, NeedLogin: true, NeedVip: true},
+
+	{Qn: QnAudio64K, Label: "64K", Fnval: FnvalDash, Audio: true},
+	{Qn: QnAudio132K, Label: "132K", Fnval: FnvalDash, Audio: true},
+	{Qn: QnAudio192K, Label: "192K", Fnval: FnvalDash, Audio: true},
+	{Qn: QnAudioDolby, Label: "Dolby", Aliases: []string{"Dolby-Atmos", "Atmos"}, Fnval: FnvalDash | FnvalDolbyAudio, NeedLogin: true, NeedVip: true, Audio: true},
+	{Qn: QnAudioHiRes, Label: "Hi-Res", Aliases: []string{"FLAC"}, Fnval: FnvalDash, NeedLogin: true, NeedVip: true, Audio: true},
+}
+
+// Quality returns the description of qn, false is returned if qn is unknown
+func (qn Qn) Quality() (Quality, bool) {
+	for _, quality := range qualities {
+		if quality.Qn == qn {
+			return quality, true
+		}
+	}
+	return Quality{}, false
+}
+
+// String returns the label of qn, or the number if qn is unknown
+func (qn Qn) String() string {
+	if quality, ok := qn.Quality(); ok {
+		return quality.Label
+	}
+	return strconv.FormatInt(int64(qn), 10)
+}
+
+// Permitted reports whether a user of the login and vip status can get qn, unknown qualities are permitted
+func (qn Qn) Permitted(login, vip bool) bool {
+	quality, ok := qn.Quality()
+	if !ok {
+		return true
+	}
+	return (login || !quality.NeedLogin) && (vip || !quality.NeedVip)
+}
+
+// rank is the position of qn in the registry, unknown qualities rank lowest
+func (qn Qn) rank() int {
+	for i, quality := range qualities {
+		if quality.Qn == qn {
+			return i
+		}
+	}
+	return -1
+}
+
+// BestQn returns the best of qns not better than limit, limit 0 means no limit.
+// False is returned if all of qns are better than limit.
+func BestQn(qns []Qn, limit Qn) (Qn, bool) {
+	var (
+		best Qn
+		rank = -2
+	)
+	for _, qn := range qns {
+		if r := qn.rank(); r > rank && (limit == 0 || r <= limit.rank()) {
+			best, rank = qn, r
+		}
+	}
+	return best, rank > -2
+}
+
+// VideoQualities returns the video qualities from the worst to the best
+func VideoQualities() []Qn {
+	return filterQualities(false)
+}
+
+// AudioQualities returns the audio qualities from the worst to the best
+func AudioQualities() []Qn {
+	return filterQualities(true)
+}
+
+func filterQualities(audio bool) []Qn {
+	qns := make([]Qn, 0, len(qualities))
+	for _, quality := range qualities {
+		if quality.Audio == audio {
+			qns = append(qns, quality.Qn)
+		}
+	}
+	return qns
+}
+
+// ParseQn parses a label like 1080P60, 4K or Hi-Res case-insensitively, or the qn itself
+func ParseQn(s string) (Qn, error) {
+	name := normalizeQuality(s)
+	for _, quality := range qualities {
+		if normalizeQuality(quality.Label) == name {
+			return quality.Qn, nil
+		}
+		for _, alias := range quality.Aliases {
+			if normalizeQuality(alias) == name {
+				return quality.Qn, nil
+			}
+		}
+	}
+	if i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+		if _, ok := Qn(i).Quality(); ok {
+			return Qn(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid quality: %s", s)
+}
+
+// normalizeQuality ignores the case, spaces, dashes and underscores of a label
+func normalizeQuality(s string) string {
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToUpper(strings.TrimSpace(s)))
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQn(t *testing.T) {
+	tests := map[string]Qn{
+		"1080P60":      Qn1080P60,
+		"1080p+":       Qn1080PPlus,
+		"4k":           Qn4k,
+		"dolby vision": QnDolbyVision,
+		"DV":           QnDolbyVision,
+		"8K":           Qn8K,
+		"127":          Qn8K,
+		"hires":        QnAudioHiRes,
+		"flac":         QnAudioHiRes,
+		"30280":        QnAudio192K,
+	}
+	for s, want := range tests {
+		qn, err := ParseQn(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, qn, s)
+	}
+	for _, s := range []string{"", "1440P", "121"} {
+		_, err := ParseQn(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestQn_Quality(t *testing.T) {
+	assert.Equal(t, "HDR", QnHDR.String())
+	assert.Equal(t, "121", Qn(121).String())
+
+	quality, ok := Qn8K.Quality()
+	assert.True(t, ok)
+	assert.True(t, quality.NeedVip)
+	assert.Equal(t, Fnval8K, quality.Fnval&Fnval8K)
+	assert.Equal(t, quality.Fnval, quality.Fnval&FnvalDashAll)
+
+	videos := VideoQualities()
+	assert.Equal(t, Qn240P, videos[0])
+	assert.Equal(t, Qn8K, videos[len(videos)-1])
+	assert.NotContains(t, videos, QnAudio192K)
+	assert.Contains(t, AudioQualities(), QnAudioDolby)
+}
+
+func TestBestQn(t *testing.T) {
+	qns := []Qn{Qn1080P60, Qn720P, Qn1080P, Qn4k}
+	qn, ok := BestQn(qns, 0)
+	assert.True(t, ok)
+	assert.Equal(t, Qn4k, qn)
+
+	qn, ok = BestQn(qns, Qn1080PPlus)
+	assert.True(t, ok)
+	assert.Equal(t, Qn1080P, qn)
+
+	_, ok = BestQn(qns, Qn480P)
+	assert.False(t, ok)
+}
+
+func TestPermittedQualities(t *testing.T) {
+	resp := &PlayUrlResp{}
+	resp.Data.AcceptQuality = []int{120, 116, 80, 64, 32}
+	assert.Equal(t, []Qn{Qn1080P, Qn720P, Qn480P}, resp.PermittedQualities(true, false))
+	assert.Equal(t, []Qn{Qn480P}, resp.PermittedQualities(false, false))
+
+	respV2 := &PlayUrlV2Resp{}
+	respV2.Result.VideoInfo.SupportFormats = []SupportFormat{
+		{Quality: 112, NeedLogin: true, NeedVip: true},
+		{Quality: 80, NeedLogin: true},
+		{Quality: 64},
+	}
+	assert.Equal(t, []Qn{Qn1080PPlus, Qn1080P, Qn720P}, respV2.PermittedQualities(true, true))
+	assert.Equal(t, []Qn{Qn720P}, respV2.PermittedQualities(false, false))
+}
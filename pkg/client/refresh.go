@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	cookieInfoPath     = "/x/passport-login/web/cookie/info"
+	refreshCookiePath  = "/x/passport-login/web/cookie/refresh"
+	confirmRefreshPath = "/x/passport-login/web/confirm/refresh"
+	correspondPath     = "/correspond/1/"
+)
+
+// correspondPublicKey encrypts the correspond path, https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/docs/login/cookie_refresh.md
+const correspondPublicKey = `-----BEGIN PUBLIC KEY-----
+MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQDLgd2OAkcGVtoE3ThUREbio0Eg
+Uc/prcajMKXvkCKFCWhJYJcLkcM2DKKcSeFpD/j6Boy538YXnR6VhcuUJOhH2x71
+nzPjfdTcqMz7djHum0qSZA0AyCBDABUqCrfNgCiJ00Ra7GmRj+YCK1NJEuewlb40
+JNrRuoEUXpabUzGB8QIDAQAB
+-----END PUBLIC KEY-----`
+
+var refreshCsrfRegexp = regexp.MustCompile(`<div id="1-name">([^<]+)</div>`)
+
+type CookieInfoResp struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	TTL     int    `json:"ttl"`
+	Data    struct {
+		Refresh   bool  `json:"refresh"`
+		Timestamp int64 `json:"timestamp"`
+	} `json:"data"`
+}
+
+type RefreshCookieResp struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	TTL     int    `json:"ttl"`
+	Data    struct {
+		Status       int    `json:"status"`
+		Message      string `json:"message"`
+		RefreshToken string `json:"refresh_token"`
+	} `json:"data"`
+}
+
+type ConfirmRefreshResp struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	TTL     int    `json:"ttl"`
+}
+
+// CookieInfo https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/docs/login/cookie_refresh.md
+// tells whether the cookie needs to be refreshed
+func (client *Client) CookieInfo() (*CookieInfoResp, error) {
+	return client.CookieInfoWithContext(context.Background())
+}
+
+func (client *Client) CookieInfoWithContext(ctx context.Context) (*CookieInfoResp, error) {
+	url := fmt.Sprintf("%s?csrf=%s", client.passportAPIURL(cookieInfoPath), client.CSRF())
+	request, err := client.newRequest(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	cookieInfoResp := &CookieInfoResp{}
+	if _, err = client.do(request, cookieInfoResp); err != nil {
+		return nil, err
+	}
+	return cookieInfoResp, nil
+}
+
+// RefreshCookies refreshes the cookie with the refresh token received on login if bilibili asks to.
+// The new cookies are stored in the client, the returned token replaces refreshToken when refreshed is true.
+func (client *Client) RefreshCookies(refreshToken string) (newRefreshToken string, refreshed bool, err error) {
+	return client.RefreshCookiesWithContext(context.Background(), refreshToken)
+}
+
+func (client *Client) RefreshCookiesWithContext(ctx context.Context, refreshToken string) (newRefreshToken string, refreshed bool, err error) {
+	cookieInfoResp, err := client.CookieInfoWithContext(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if !cookieInfoResp.Data.Refresh {
+		return refreshToken, false, nil
+	}
+	path, err := CorrespondPath(cookieInfoResp.Data.Timestamp)
+	if err != nil {
+		return "", false, err
+	}
+	refreshCsrf, err := client.refreshCsrf(ctx, path)
+	if err != nil {
+		return "", false, err
+	}
+	refreshCookieResp := &RefreshCookieResp{}
+	if err = client.postForm(ctx, client.passportAPIURL(refreshCookiePath), url.Values{
+		"csrf":          {client.CSRF()},
+		"refresh_csrf":  {refreshCsrf},
+		"source":        {"main_web"},
+		"refresh_token": {refreshToken},
+	}, refreshCookieResp); err != nil {
+		return "", false, err
+	}
+	// the csrf is the new bili_jct, the refresh token is the old one
+	if err = client.postForm(ctx, client.passportAPIURL(confirmRefreshPath), url.Values{
+		"csrf":          {client.CSRF()},
+		"refresh_token": {refreshToken},
+	}, &ConfirmRefreshResp{}); err != nil {
+		return "", false, err
+	}
+	return refreshCookieResp.Data.RefreshToken, true, nil
+}
+
+// CorrespondPath encrypts "refresh_{timestamp}" with the public key of bilibili, timestamp is in milliseconds
+func CorrespondPath(timestamp int64) (string, error) {
+	block, _ := pem.Decode([]byte(correspondPublicKey))
+	if block == nil {
+		return "", fmt.Errorf("invalid correspond public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return "", fmt.Errorf("correspond public key is not rsa")
+	}
+	encrypted, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, []byte("refresh_"+strconv.FormatInt(timestamp, 10)), nil)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(encrypted), nil
+}
+
+// refreshCsrf reads the refresh_csrf from the correspond page
+func (client *Client) refreshCsrf(ctx context.Context, path string) (string, error) {
+	request, err := client.newRequest(ctx, http.MethodGet, client.webPageURL(correspondPath+path), nil)
+	if err != nil {
+		return "", err
+	}
+	var body []byte
+	if _, err = client.send(request, readBody(&body)); err != nil {
+		return "", err
+	}
+	subs := refreshCsrfRegexp.FindSubmatch(body)
+	if len(subs) != 2 {
+		return "", fmt.Errorf("refresh_csrf not found in correspond page")
+	}
+	return strings.TrimSpace(string(subs[1])), nil
+}
+
+func (client *Client) postForm(ctx context.Context, rawURL string, form url.Values, v interface{}) error {
+	request, err := client.newRequest(ctx, http.MethodPost, rawURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = client.do(request, v)
+	return err
+}
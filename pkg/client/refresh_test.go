@@ -0,0 +1,58 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_RefreshCookies(t *testing.T) {
+	var confirmed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == cookieInfoPath:
+			assert.Equal(t, "old_csrf", r.URL.Query().Get("csrf"))
+			_, _ = w.Write([]byte(`{"code":0,"data":{"refresh":true,"timestamp":1684466082014}}`))
+		case len(r.URL.Path) > len(correspondPath) && r.URL.Path[:len(correspondPath)] == correspondPath:
+			assert.Len(t, r.URL.Path[len(correspondPath):], 256)
+			_, _ = w.Write([]byte(`<html><body><div id="1-name">refresh_csrf</div></body></html>`))
+		case r.URL.Path == refreshCookiePath:
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "old_csrf", r.FormValue("csrf"))
+			assert.Equal(t, "refresh_csrf", r.FormValue("refresh_csrf"))
+			assert.Equal(t, "old_token", r.FormValue("refresh_token"))
+			http.SetCookie(w, &http.Cookie{Name: CookieBiliJct, Value: "new_csrf", Path: "/"})
+			_, _ = w.Write([]byte(`{"code":0,"data":{"status":0,"refresh_token":"new_token"}}`))
+		case r.URL.Path == confirmRefreshPath:
+			assert.Equal(t, "new_csrf", r.FormValue("csrf"))
+			assert.Equal(t, "old_token", r.FormValue("refresh_token"))
+			confirmed = true
+			_, _ = w.Write([]byte(`{"code":0}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := New(WithBaseURL(server.URL), WithPassportURL(server.URL), WithWebURL(server.URL))
+	client.SetCookie([]string{"bili_jct=old_csrf; Path=/", "SESSDATA=sess; Path=/"})
+	token, refreshed, err := client.RefreshCookies("old_token")
+	assert.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.True(t, confirmed)
+	assert.Equal(t, "new_token", token)
+	assert.Equal(t, "new_csrf", client.CSRF())
+}
+
+func TestClient_RefreshCookies_notNeeded(t *testing.T) {
+	server := newJSONServer(`{"code":0,"data":{"refresh":false,"timestamp":1684466082014}}`)
+	defer server.Close()
+
+	client := New(WithPassportURL(server.URL))
+	token, refreshed, err := client.RefreshCookies("token")
+	assert.NoError(t, err)
+	assert.False(t, refreshed)
+	assert.Equal(t, "token", token)
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+// envelope is the status shared by every api response
+type envelope struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	TTL     int    `json:"ttl"`
+}
+
+func (e *envelope) err() error {
+	if e.Code != 0 {
+		return errors.New(e.Code, e.Message)
+	}
+	return nil
+}
+
+// decodeResponse decodes the whole body of resp into v,
+// v is filled even if the api responds with a non-zero code
+func decodeResponse(resp *http.Response, v interface{}) (*envelope, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	env := &envelope{}
+	if err = json.Unmarshal(body, env); err != nil {
+		return nil, err
+	}
+	if v != nil {
+		if err = json.Unmarshal(body, v); err != nil {
+			return nil, err
+		}
+	}
+	return env, env.err()
+}
+
+// do sends request and decodes the response into v
+func (client *Client) do(request *http.Request, v interface{}) (http.Header, error) {
+	return client.send(request, func(resp *http.Response) error {
+		_, err := decodeResponse(resp, v)
+		return err
+	})
+}
+
+// send sends request and hands the response to handle, the request is
+// rate limited and retried according to the options of client
+func (client *Client) send(request *http.Request, handle func(resp *http.Response) error) (http.Header, error) {
+	ctx := request.Context()
+	for attempt := 1; ; attempt++ {
+		header, err := client.sendOnce(request, handle)
+		if err == nil || client.retryPolicy == nil {
+			return header, err
+		}
+		delay, retry := client.retryPolicy.Backoff(attempt, header, err)
+		if !retry {
+			return header, err
+		}
+		if err = sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+		if request, err = rewind(request); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (client *Client) sendOnce(request *http.Request, handle func(resp *http.Response) error) (http.Header, error) {
+	if limiter := client.limiter(request.URL.Host); limiter != nil {
+		if err := limiter.Wait(request.Context()); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := client.httpClient().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return resp.Header, handle(resp)
+}
+
+// readBody returns a handle of send that reads the body of a 200 response into body
+func readBody(body *[]byte) func(resp *http.Response) error {
+	return func(resp *http.Response) (err error) {
+		if resp.StatusCode != http.StatusOK {
+			return errors.ErrUnexpectedStatusCode(resp.StatusCode)
+		}
+		*body, err = io.ReadAll(resp.Body)
+		return err
+	}
+}
+
+// rewind returns a copy of request whose body can be sent again
+func rewind(request *http.Request) (*http.Request, error) {
+	clone := request.Clone(request.Context())
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newJSONServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestStatusError(t *testing.T) {
+	server := newJSONServer(`{"code":-10403,"message":"抱歉您所在地区不可观看！"}`)
+	defer server.Close()
+
+	client := New(WithBaseURL(server.URL))
+	_, err := client.PlayUrlV2(1, Qn1080P, FnvalDash)
+	assert.ErrorIs(t, err, errors.ErrRegionLocked)
+	assert.False(t, stderrors.Is(err, errors.ErrNotLogin))
+
+	var statusErr errors.StatusError
+	assert.True(t, stderrors.As(err, &statusErr))
+	assert.Equal(t, errors.CodeRegionLocked, statusErr.Code)
+	assert.Equal(t, "抱歉您所在地区不可观看！", statusErr.Cause)
+}
+
+func TestUnexpectedStatusCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := New(WithBaseURL(server.URL)).NavInfo()
+	assert.Equal(t, errors.ErrUnexpectedStatusCode(http.StatusBadGateway), err)
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	stderrors "errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+// RetryPolicy decides whether a failed request should be sent again
+type RetryPolicy interface {
+	// Backoff is called after the attempt-th failure, header is nil if no response was received.
+	// It returns how long to wait before the next attempt and false to give up.
+	Backoff(attempt int, header http.Header, err error) (time.Duration, bool)
+}
+
+// ExponentialBackoff retries with exponentially growing delays and full jitter,
+// a Retry-After header sent by the server takes precedence over the computed delay
+type ExponentialBackoff struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// RetryIf reports whether err is worth retrying, default is Retryable
+	RetryIf func(err error) bool
+}
+
+// NewExponentialBackoff returns an ExponentialBackoff starting at 500ms and capped at 30s
+func NewExponentialBackoff(maxRetries int) *ExponentialBackoff {
+	return &ExponentialBackoff{
+		MaxRetries: maxRetries,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   30 * time.Second,
+	}
+}
+
+func (b *ExponentialBackoff) Backoff(attempt int, header http.Header, err error) (time.Duration, bool) {
+	retryIf := b.RetryIf
+	if retryIf == nil {
+		retryIf = Retryable
+	}
+	if attempt > b.MaxRetries || !retryIf(err) {
+		return 0, false
+	}
+	if delay, ok := retryAfter(header); ok {
+		return delay, true
+	}
+	delay := b.BaseDelay << (attempt - 1)
+	if delay <= 0 || (b.MaxDelay > 0 && delay > b.MaxDelay) {
+		delay = b.MaxDelay
+	}
+	if delay <= 0 {
+		return 0, true
+	}
+	// full jitter spreads the retries of many clients over the whole window
+	return time.Duration(rand.Int63n(int64(delay) + 1)), true
+}
+
+// Retryable reports whether err is a transient failure: network errors,
+// http 429/5xx and the throttling codes -412, -352 and -509
+func Retryable(err error) bool {
+	if err == nil || stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusCode errors.ErrUnexpectedStatusCode
+	if stderrors.As(err, &statusCode) {
+		return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+	}
+	if stderrors.Is(err, errors.ErrRequestIntercepted) ||
+		stderrors.Is(err, errors.ErrRiskControl) ||
+		stderrors.Is(err, errors.ErrTooManyRequests) {
+		return true
+	}
+	var netErr net.Error
+	return stderrors.As(err, &netErr)
+}
+
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_retry(t *testing.T) {
+	var count int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		switch count {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			_, _ = w.Write([]byte(`{"code":-412,"message":"请求被拦截"}`))
+		default:
+			_, _ = w.Write([]byte(`{"code":0,"data":{"isLogin":true}}`))
+		}
+	}))
+	defer server.Close()
+
+	policy := NewExponentialBackoff(2)
+	policy.BaseDelay = time.Millisecond
+	client := New(WithBaseURL(server.URL), WithRetryPolicy(policy))
+	info, err := client.NavInfo()
+	assert.NoError(t, err)
+	assert.True(t, info.Data.IsLogin)
+	assert.Equal(t, 3, count)
+
+	count = 1
+	policy.MaxRetries = 0
+	_, err = client.NavInfo()
+	assert.ErrorIs(t, err, errors.ErrRequestIntercepted)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	policy := NewExponentialBackoff(5)
+	policy.MaxDelay = 2 * time.Second
+	for attempt := 1; attempt <= 5; attempt++ {
+		limit := policy.BaseDelay << (attempt - 1)
+		if limit > policy.MaxDelay {
+			limit = policy.MaxDelay
+		}
+		for i := 0; i < 100; i++ {
+			delay, ok := policy.Backoff(attempt, nil, errors.ErrRiskControl)
+			assert.True(t, ok)
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, limit)
+		}
+	}
+	_, ok := policy.Backoff(6, nil, errors.ErrRiskControl)
+	assert.False(t, ok)
+
+	header := http.Header{}
+	header.Set("Retry-After", "3")
+	delay, ok := policy.Backoff(1, header, errors.ErrRiskControl)
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, delay)
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, Retryable(errors.ErrRiskControl))
+	assert.True(t, Retryable(errors.ErrUnexpectedStatusCode(http.StatusTooManyRequests)))
+	assert.False(t, Retryable(errors.ErrUnexpectedStatusCode(http.StatusNotFound)))
+	assert.False(t, Retryable(errors.ErrNotLogin))
+	assert.False(t, Retryable(context.Canceled))
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(100, 1)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, limiter.Wait(context.Background()))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter = NewRateLimiter(0.001, 1)
+	assert.NoError(t, limiter.Wait(ctx))
+	assert.ErrorIs(t, limiter.Wait(ctx), context.Canceled)
+}
@@ -1,16 +1,13 @@
 package client
 
 import (
-	"encoding/json"
-	"io/ioutil"
+	"context"
 	"net/http"
 	"net/url"
-
-	"github.com/misssonder/bilibili/pkg/errors"
 )
 
 const (
-	seasonSectionInfoUrl = "https://api.bilibili.com/pgc/view/web/season"
+	seasonSectionInfoPath = "/pgc/view/web/season"
 )
 
 type SeasonSectionResp struct {
@@ -289,7 +286,11 @@ type SeasonSectionResp struct {
 }
 
 func (client *Client) SeasonSection(ssID string, epID string) (*SeasonSectionResp, error) {
-	u, err := url.Parse(seasonSectionInfoUrl)
+	return client.SeasonSectionWithContext(context.Background(), ssID, epID)
+}
+
+func (client *Client) SeasonSectionWithContext(ctx context.Context, ssID string, epID string) (*SeasonSectionResp, error) {
+	u, err := url.Parse(client.apiURL(seasonSectionInfoPath))
 	if err != nil {
 		return nil, err
 	}
@@ -301,33 +302,13 @@ func (client *Client) SeasonSection(ssID string, epID string) (*SeasonSectionRes
 		values.Set("ep_id", epID)
 	}
 	u.RawQuery = values.Encode()
-	client.HttpClient = &http.Client{}
-	request, err := client.newCookieRequest(http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := client.HttpClient.Do(request)
+	request, err := client.newRequest(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
-
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
 	seasonSectionResp := &SeasonSectionResp{}
-	if err = json.Unmarshal(body, seasonSectionResp); err != nil {
+	if _, err = client.do(request, seasonSectionResp); err != nil {
 		return nil, err
 	}
-	if seasonSectionResp.Code != 0 {
-		return nil, errors.StatusError{Code: seasonSectionResp.Code, Cause: seasonSectionResp.Message}
-	}
-
 	return seasonSectionResp, nil
 }
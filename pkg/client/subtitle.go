@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/misssonder/bilibili/pkg/subtitle"
+	"github.com/misssonder/bilibili/pkg/video"
+)
+
+const (
+	playerInfoPath = "/x/player/wbi/v2"
+)
+
+// SubtitleInfo is a subtitle track of a video, Lan of the ai-generated ones starts with ai-
+type SubtitleInfo struct {
+	ID          int64  `json:"id"`
+	Lan         string `json:"lan"`
+	LanDoc      string `json:"lan_doc"`
+	IsLock      bool   `json:"is_lock"`
+	SubtitleURL string `json:"subtitle_url"`
+	Type        int    `json:"type"`
+	AiType      int    `json:"ai_type"`
+	AiStatus    int    `json:"ai_status"`
+}
+
+// IsAI reports whether the subtitle is generated by ai
+func (info SubtitleInfo) IsAI() bool {
+	return strings.HasPrefix(info.Lan, "ai-") || info.Type == 1
+}
+
+type PlayerInfoResp struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	TTL     int    `json:"ttl"`
+	Data    struct {
+		Aid      int    `json:"aid"`
+		Bvid     string `json:"bvid"`
+		Cid      int    `json:"cid"`
+		Subtitle struct {
+			AllowSubmit bool           `json:"allow_submit"`
+			Lan         string         `json:"lan"`
+			LanDoc      string         `json:"lan_doc"`
+			Subtitles   []SubtitleInfo `json:"subtitles"`
+		} `json:"subtitle"`
+	} `json:"data"`
+}
+
+// PlayerInfo https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/docs/video/player.md
+// lists the subtitles of a page including the ai-generated ones
+func (client *Client) PlayerInfo(bvid string, cid int64) (*PlayerInfoResp, error) {
+	return client.PlayerInfoWithContext(context.Background(), bvid, cid)
+}
+
+func (client *Client) PlayerInfoWithContext(ctx context.Context, bvid string, cid int64) (*PlayerInfoResp, error) {
+	id, err := video.ExtractBvID(bvid)
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	values.Set("bvid", id)
+	values.Set("cid", strconv.FormatInt(cid, 10))
+	if values, err = client.SignWbi(ctx, values); err != nil {
+		return nil, err
+	}
+	request, err := client.newRequest(ctx, http.MethodGet, client.apiURL(playerInfoPath)+"?"+values.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	playerInfoResp := &PlayerInfoResp{}
+	if _, err = client.do(request, playerInfoResp); err != nil {
+		return nil, err
+	}
+	return playerInfoResp, nil
+}
+
+// SubtitleBody fetches the json body of SubtitleInfo.SubtitleURL
+func (client *Client) SubtitleBody(subtitleURL string) (*subtitle.Subtitle, error) {
+	return client.SubtitleBodyWithContext(context.Background(), subtitleURL)
+}
+
+func (client *Client) SubtitleBodyWithContext(ctx context.Context, subtitleURL string) (*subtitle.Subtitle, error) {
+	// the url is protocol relative, e.g. //aisubtitle.hdslb.com/bfs/ai_subtitle/prod/xxx
+	if strings.HasPrefix(subtitleURL, "//") {
+		subtitleURL = "https:" + subtitleURL
+	}
+	request, err := client.newRequest(ctx, http.MethodGet, subtitleURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if _, err = client.send(request, readBody(&data)); err != nil {
+		return nil, err
+	}
+	body := &subtitle.Subtitle{}
+	if err = json.Unmarshal(data, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
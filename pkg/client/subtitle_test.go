@@ -0,0 +1,41 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_PlayerInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case navInfoPath:
+			_, _ = w.Write([]byte(`{"code":0,"data":{"wbi_img":{
+				"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",
+				"sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`))
+		case playerInfoPath:
+			assert.NotEmpty(t, r.URL.Query().Get("w_rid"))
+			_, _ = w.Write([]byte(`{"code":0,"data":{"subtitle":{"subtitles":[
+				{"id":1,"lan":"zh-CN","lan_doc":"中文（中国）","subtitle_url":"//` + r.Host + `/zh.json","type":0},
+				{"id":2,"lan":"ai-zh","lan_doc":"中文（自动生成）","subtitle_url":"","type":1,"ai_type":0,"ai_status":2}]}}}`))
+		case "/zh.json":
+			_, _ = w.Write([]byte(`{"font_size":0.4,"lang":"zh","body":[{"from":0.5,"to":1,"location":2,"content":"你好"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	client := New(WithBaseURL(server.URL))
+	info, err := client.PlayerInfo("BV117411r7R1", 1)
+	assert.NoError(t, err)
+	subtitles := info.Data.Subtitle.Subtitles
+	assert.Len(t, subtitles, 2)
+	assert.False(t, subtitles[0].IsAI())
+	assert.True(t, subtitles[1].IsAI())
+
+	// httptest serves plain http
+	body, err := client.SubtitleBody("http:" + subtitles[0].SubtitleURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "你好", body.Body[0].Content)
+}
@@ -1,15 +1,12 @@
 package client
 
 import (
-	"encoding/json"
-	"io/ioutil"
+	"context"
 	"net/http"
-
-	"github.com/misssonder/bilibili/pkg/errors"
 )
 
 const (
-	mySpaceInfoUrl = "https://api.bilibili.com/x/space/myinfo"
+	mySpaceInfoPath = "/x/space/myinfo"
 )
 
 type MySpaceInfoResp struct {
@@ -124,34 +121,17 @@ type MySpaceInfoResp struct {
 
 // MySpaceInfo https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/user/info.md
 func (client *Client) MySpaceInfo() (*MySpaceInfoResp, error) {
-	client.HttpClient = &http.Client{}
-	request, err := client.newCookieRequest(http.MethodGet, mySpaceInfoUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := client.HttpClient.Do(request)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
-	}
+	return client.MySpaceInfoWithContext(context.Background())
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+func (client *Client) MySpaceInfoWithContext(ctx context.Context) (*MySpaceInfoResp, error) {
+	request, err := client.newRequest(ctx, http.MethodGet, client.apiURL(mySpaceInfoPath), nil)
 	if err != nil {
 		return nil, err
 	}
-
 	mySpaceInfoResp := &MySpaceInfoResp{}
-	if err = json.Unmarshal(body, mySpaceInfoResp); err != nil {
+	if _, err = client.do(request, mySpaceInfoResp); err != nil {
 		return nil, err
 	}
-
-	if mySpaceInfoResp.Code != 0 {
-		return nil, errors.StatusError{Code: mySpaceInfoResp.Code, Cause: mySpaceInfoResp.Message}
-	}
-
 	return mySpaceInfoResp, nil
 }
@@ -1,20 +1,19 @@
 package client
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 
-	"github.com/misssonder/bilibili/pkg/errors"
 	"github.com/misssonder/bilibili/pkg/video"
 )
 
 const (
-	videoInfoUrl = "https://api.bilibili.com/x/web-interface/view"
-	playUrl      = "https://api.bilibili.com/x/player/playurl"
-	playUrlV2    = "https://api.bilibili.com/pgc/player/web/v2/playurl"
+	videoInfoPath = "/x/web-interface/view"
+	playUrlPath   = "/x/player/wbi/playurl"
+	playUrlV2Path = "/pgc/player/web/v2/playurl"
 )
 
 type VideoInfoResp struct {
@@ -108,8 +107,8 @@ type VideoInfoResp struct {
 			} `json:"dimension"`
 		} `json:"pages"`
 		Subtitle struct {
-			AllowSubmit bool          `json:"allow_submit"`
-			List        []interface{} `json:"list"`
+			AllowSubmit bool           `json:"allow_submit"`
+			List        []SubtitleInfo `json:"list"`
 		} `json:"subtitle"`
 		Staff []struct {
 			Mid   int    `json:"mid"`
@@ -173,31 +172,24 @@ type PlayUrlResp struct {
 	Message string `json:"message"`
 	TTL     int    `json:"ttl"`
 	Data    struct {
-		From              string   `json:"from"`
-		Result            string   `json:"result"`
-		Message           string   `json:"message"`
-		Quality           int      `json:"quality"`
-		Format            string   `json:"format"`
-		Timelength        int      `json:"timelength"`
-		AcceptFormat      string   `json:"accept_format"`
-		AcceptDescription []string `json:"accept_description"`
-		AcceptQuality     []int    `json:"accept_quality"`
-		VideoCodecid      int      `json:"video_codecid"`
-		SeekParam         string   `json:"seek_param"`
-		SeekType          string   `json:"seek_type"`
-		Durl              []Durl   `json:"durl"`
-		Dash              Dash     `json:"dash"`
-		SupportFormats    []struct {
-			Quality        int         `json:"quality"`
-			Format         string      `json:"format"`
-			NewDescription string      `json:"new_description"`
-			DisplayDesc    string      `json:"display_desc"`
-			Superscript    string      `json:"superscript"`
-			Codecs         interface{} `json:"codecs"`
-		} `json:"support_formats"`
-		HighFormat   interface{} `json:"high_format"`
-		LastPlayTime int         `json:"last_play_time"`
-		LastPlayCid  int         `json:"last_play_cid"`
+		From              string          `json:"from"`
+		Result            string          `json:"result"`
+		Message           string          `json:"message"`
+		Quality           int             `json:"quality"`
+		Format            string          `json:"format"`
+		Timelength        int             `json:"timelength"`
+		AcceptFormat      string          `json:"accept_format"`
+		AcceptDescription []string        `json:"accept_description"`
+		AcceptQuality     []int           `json:"accept_quality"`
+		VideoCodecid      int             `json:"video_codecid"`
+		SeekParam         string          `json:"seek_param"`
+		SeekType          string          `json:"seek_type"`
+		Durl              []Durl          `json:"durl"`
+		Dash              Dash            `json:"dash"`
+		SupportFormats    []SupportFormat `json:"support_formats"`
+		HighFormat        interface{}     `json:"high_format"`
+		LastPlayTime      int             `json:"last_play_time"`
+		LastPlayCid       int             `json:"last_play_cid"`
 	} `json:"data"`
 }
 
@@ -273,30 +265,19 @@ type PlayUrlV2Resp struct {
 				RecordIcon string `json:"record_icon"`
 				Record     string `json:"record"`
 			} `json:"record_info"`
-			IsDrm          bool   `json:"is_drm"`
-			NoRexcode      int    `json:"no_rexcode"`
-			Format         string `json:"format"`
-			SupportFormats []struct {
-				DisplayDesc    string   `json:"display_desc"`
-				SubDescription string   `json:"sub_description"`
-				Superscript    string   `json:"superscript"`
-				NeedLogin      bool     `json:"need_login,omitempty"`
-				Codecs         []string `json:"codecs"`
-				Format         string   `json:"format"`
-				Description    string   `json:"description"`
-				NeedVip        bool     `json:"need_vip,omitempty"`
-				Quality        int      `json:"quality"`
-				NewDescription string   `json:"new_description"`
-			} `json:"support_formats"`
-			Message      string `json:"message"`
-			Quality      int    `json:"quality"`
-			Timelength   int    `json:"timelength"`
-			HasPaid      bool   `json:"has_paid"`
-			DrmType      string `json:"drm_type"`
-			VipStatus    int    `json:"vip_status"`
-			Durl         []Durl `json:"durl"`
-			Dash         Dash   `json:"dash"`
-			ClipInfoList []struct {
+			IsDrm          bool            `json:"is_drm"`
+			NoRexcode      int             `json:"no_rexcode"`
+			Format         string          `json:"format"`
+			SupportFormats []SupportFormat `json:"support_formats"`
+			Message        string          `json:"message"`
+			Quality        int             `json:"quality"`
+			Timelength     int             `json:"timelength"`
+			HasPaid        bool            `json:"has_paid"`
+			DrmType        string          `json:"drm_type"`
+			VipStatus      int             `json:"vip_status"`
+			Durl           []Durl          `json:"durl"`
+			Dash           Dash            `json:"dash"`
+			ClipInfoList   []struct {
 				MaterialNo int    `json:"materialNo"`
 				Start      int    `json:"start"`
 				End        int    `json:"end"`
@@ -390,50 +371,92 @@ type PlayUrlV2Resp struct {
 	} `json:"result"`
 }
 
+// SupportFormat is a quality the video supports, NeedLogin and NeedVip are only set by PlayUrlV2
+type SupportFormat struct {
+	Quality        int      `json:"quality"`
+	Format         string   `json:"format"`
+	Description    string   `json:"description"`
+	NewDescription string   `json:"new_description"`
+	DisplayDesc    string   `json:"display_desc"`
+	SubDescription string   `json:"sub_description"`
+	Superscript    string   `json:"superscript"`
+	Codecs         []string `json:"codecs"`
+	NeedLogin      bool     `json:"need_login,omitempty"`
+	NeedVip        bool     `json:"need_vip,omitempty"`
+}
+
+// Permitted reports whether a user of the login and vip status can get the format
+func (format SupportFormat) Permitted(login, vip bool) bool {
+	return (login || !format.NeedLogin) && (vip || !format.NeedVip)
+}
+
+// PermittedQualities returns the accepted qualities a user of the login and vip status can get,
+// the requirements come from the quality registry as PlayUrl does not tell them
+func (resp *PlayUrlResp) PermittedQualities(login, vip bool) []Qn {
+	qns := make([]Qn, 0, len(resp.Data.AcceptQuality))
+	for _, quality := range resp.Data.AcceptQuality {
+		if qn := Qn(quality); qn.Permitted(login, vip) {
+			qns = append(qns, qn)
+		}
+	}
+	return qns
+}
+
+// PermittedQualities returns the supported qualities a user of the login and vip status can get
+func (resp *PlayUrlV2Resp) PermittedQualities(login, vip bool) []Qn {
+	qns := make([]Qn, 0, len(resp.Result.VideoInfo.SupportFormats))
+	for _, format := range resp.Result.VideoInfo.SupportFormats {
+		if format.Permitted(login, vip) {
+			qns = append(qns, Qn(format.Quality))
+		}
+	}
+	return qns
+}
+
 type Dash struct {
-	Duration      int     `json:"duration"`
-	MinBufferTime float64 `json:"min_buffer_time"`
-	Video         []struct {
-		ID           int      `json:"id"`
-		BaseURL      string   `json:"base_url"`
-		BackupURL    []string `json:"backup_url"`
-		Bandwidth    int      `json:"bandwidth"`
-		MimeType     string   `json:"mime_type"`
-		Codecs       string   `json:"codecs"`
-		Width        int      `json:"width"`
-		Height       int      `json:"height"`
-		FrameRate    string   `json:"frame_rate"`
-		Sar          string   `json:"sar"`
-		StartWithSap int      `json:"start_with_sap"`
-		SegmentBase  struct {
-			Initialization string `json:"initialization"`
-			IndexRange     string `json:"index_range"`
-		} `json:"segment_base"`
-		Codecid int `json:"codecid"`
-	} `json:"video"`
-	Audio []struct {
-		ID           int      `json:"id"`
-		BaseURL      string   `json:"base_url"`
-		BackupURL    []string `json:"backup_url"`
-		Bandwidth    int      `json:"bandwidth"`
-		MimeType     string   `json:"mime_type"`
-		Codecs       string   `json:"codecs"`
-		Width        int      `json:"width"`
-		Height       int      `json:"height"`
-		FrameRate    string   `json:"frame_rate"`
-		Sar          string   `json:"sar"`
-		StartWithSap int      `json:"start_with_sap"`
-		SegmentBase  struct {
-			Initialization string `json:"initialization"`
-			IndexRange     string `json:"index_range"`
-		} `json:"segment_base"`
-		Codecid int `json:"codecid"`
-	} `json:"audio"`
-	Dolby struct {
-		Type  int         `json:"type"`
-		Audio interface{} `json:"audio"`
-	} `json:"dolby"`
-	Flac interface{} `json:"flac"`
+	Duration      int         `json:"duration"`
+	MinBufferTime float64     `json:"min_buffer_time"`
+	Video         []DashMedia `json:"video"`
+	Audio         []DashMedia `json:"audio"`
+	Dolby         DashDolby   `json:"dolby"`
+	Flac          *DashFlac   `json:"flac"`
+}
+
+// DashDolby is the Dolby Atmos audio of Dash, Type is 0 if there is none
+type DashDolby struct {
+	Type  int         `json:"type"`
+	Audio []DashMedia `json:"audio"`
+}
+
+// DashFlac is the Hi-Res lossless audio of Dash, it is null if there is none
+type DashFlac struct {
+	Display bool       `json:"display"`
+	Audio   *DashMedia `json:"audio"`
+}
+
+// DashMedia is a video or audio stream of Dash
+type DashMedia struct {
+	ID           int      `json:"id"`
+	BaseURL      string   `json:"base_url"`
+	BackupURL    []string `json:"backup_url"`
+	Bandwidth    int      `json:"bandwidth"`
+	MimeType     string   `json:"mime_type"`
+	Codecs       string   `json:"codecs"`
+	Width        int      `json:"width"`
+	Height       int      `json:"height"`
+	FrameRate    string   `json:"frame_rate"`
+	Sar          string   `json:"sar"`
+	StartWithSap int      `json:"start_with_sap"`
+	SegmentBase  struct {
+		Initialization string `json:"initialization"`
+		IndexRange     string `json:"index_range"`
+	} `json:"segment_base"`
+	Codecid Codecid `json:"codecid"`
+}
+
+// URLs returns the base url followed by the backup urls
+func (media DashMedia) URLs() []string {
+	return mirrors(media.BaseURL, media.BackupURL)
 }
 
 type Durl struct {
@@ -447,35 +470,38 @@ type Durl struct {
 	Md5       string   `json:"md5"`
 }
 
-func (client *Client) GetVideoInfo(id string) (*VideoInfoResp, error) {
-	url := fmt.Sprintf("%s?bvid=%s", videoInfoUrl, id)
-	client.HttpClient = &http.Client{}
-	request, err := client.newCookieRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := client.HttpClient.Do(request)
-	if err != nil {
-		return nil, err
+// URLs returns the url followed by the backup urls
+func (durl Durl) URLs() []string {
+	return mirrors(durl.URL, durl.BackupURL)
+}
+
+func mirrors(url string, backups []string) []string {
+	urls := make([]string, 0, len(backups)+1)
+	if len(url) > 0 {
+		urls = append(urls, url)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	for _, backup := range backups {
+		if len(backup) > 0 && backup != url {
+			urls = append(urls, backup)
+		}
 	}
+	return urls
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+func (client *Client) GetVideoInfo(id string) (*VideoInfoResp, error) {
+	return client.GetVideoInfoWithContext(context.Background(), id)
+}
+
+func (client *Client) GetVideoInfoWithContext(ctx context.Context, id string) (*VideoInfoResp, error) {
+	url := fmt.Sprintf("%s?bvid=%s", client.apiURL(videoInfoPath), id)
+	request, err := client.newRequest(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-
 	videoInfoResp := &VideoInfoResp{}
-	if err = json.Unmarshal(body, videoInfoResp); err != nil {
+	if _, err = client.do(request, videoInfoResp); err != nil {
 		return nil, err
 	}
-	if videoInfoResp.Code != 0 {
-		return nil, errors.StatusError{Code: videoInfoResp.Code, Cause: videoInfoResp.Message}
-	}
-
 	return videoInfoResp, nil
 }
 
@@ -483,134 +509,66 @@ func (client *Client) GetVideoInfo(id string) (*VideoInfoResp, error) {
 type Fnval int64
 
 const (
-	FnvalMP4  Fnval = 1
-	FnvalDash Fnval = 16
-	FnvalHDR  Fnval = 64
-	Fnval4K   Fnval = 128
+	FnvalMP4         Fnval = 1
+	FnvalDash        Fnval = 16
+	FnvalHDR         Fnval = 64
+	Fnval4K          Fnval = 128
+	FnvalDolbyAudio  Fnval = 256
+	FnvalDolbyVision Fnval = 512
+	Fnval8K          Fnval = 1024
+	FnvalAV1         Fnval = 2048
+
+	// FnvalDashAll requests dash with every optional stream: HDR, 4K, Dolby Atmos, Dolby Vision, 8K and AV1
+	FnvalDashAll = FnvalDash | FnvalHDR | Fnval4K | FnvalDolbyAudio | FnvalDolbyVision | Fnval8K | FnvalAV1
 
 	FnvalAudio64K  Fnval = 30216
 	FnvalAudio132K Fnval = 30232
 	FnvalAudio192K Fnval = 30280
 )
 
-type Qn int64
-
-func (qn Qn) String() string {
-	switch qn {
-	case Qn240P:
-		return "240P"
-	case Qn360P:
-		return "360P"
-	case Qn480P:
-		return "480P"
-	case Qn720P:
-		return "720P"
-	case Qn720P60:
-		return "720P60"
-	case Qn1080P:
-		return "1080P"
-	case Qn1080PPlus:
-		return "1080P+"
-	case Qn1080P60:
-		return "1080P60"
-	case Qn4k:
-		return "4K"
-	case QnAudio64K:
-		return "64K"
-	case QnAudio132K:
-		return "132K"
-	case QnAudio192K:
-		return "192K"
-	case QnAudioDolby:
-		return "Dolby"
-	case QnAudioHiRes:
-		return "Hi-Res"
-	default:
-		return ""
-	}
+func (client *Client) PlayUrl(bvid string, cid int64, qn Qn, fnval Fnval) (*PlayUrlResp, error) {
+	return client.PlayUrlWithContext(context.Background(), bvid, cid, qn, fnval)
 }
 
-const (
-	Qn240P      Qn = 6
-	Qn360P      Qn = 16
-	Qn480P      Qn = 32
-	Qn720P      Qn = 64
-	Qn720P60    Qn = 74
-	Qn1080P     Qn = 80
-	Qn1080PPlus Qn = 112
-	Qn1080P60   Qn = 116
-	Qn4k        Qn = 120
-
-	QnAudio64K   Qn = 30216
-	QnAudio132K  Qn = 30232
-	QnAudio192K  Qn = 30280
-	QnAudioDolby Qn = 30250
-	QnAudioHiRes Qn = 30251
-)
-
-func (client *Client) PlayUrl(bvid string, cid int64, qn Qn, fnval Fnval) (*PlayUrlResp, error) {
+func (client *Client) PlayUrlWithContext(ctx context.Context, bvid string, cid int64, qn Qn, fnval Fnval) (*PlayUrlResp, error) {
 	id, err := video.ExtractBvID(bvid)
 	if err != nil {
 		return nil, err
 	}
-	url := fmt.Sprintf("%s?bvid=%s&cid=%d&qn=%d&fourk=1&fnval=%d", playUrl, id, cid, qn, fnval)
-	client.HttpClient = &http.Client{}
-	request, err := client.newCookieRequest(http.MethodGet, url, nil)
-	if err != nil {
+	values := url.Values{}
+	values.Set("bvid", id)
+	values.Set("cid", strconv.FormatInt(cid, 10))
+	values.Set("qn", strconv.FormatInt(int64(qn), 10))
+	values.Set("fourk", "1")
+	values.Set("fnval", strconv.FormatInt(int64(fnval), 10))
+	if values, err = client.SignWbi(ctx, values); err != nil {
 		return nil, err
 	}
-	resp, err := client.HttpClient.Do(request)
+	request, err := client.newRequest(ctx, http.MethodGet, client.apiURL(playUrlPath)+"?"+values.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
 	playUrlResp := &PlayUrlResp{}
-	if err = json.Unmarshal(body, playUrlResp); err != nil {
+	if _, err = client.do(request, playUrlResp); err != nil {
 		return nil, err
 	}
-	if playUrlResp.Code != 0 {
-		return nil, errors.StatusError{Code: playUrlResp.Code, Cause: playUrlResp.Message}
-	}
-
 	return playUrlResp, nil
 }
 
 func (client *Client) PlayUrlV2(epid int64, qn Qn, fnval Fnval) (*PlayUrlV2Resp, error) {
-	url := fmt.Sprintf("%s?ep_id=%d&qn=%d&fnval=%d&fnver=0&fourk=1&support_multi_audio=true&gaia_source=&is_main_page=true&need_fragment=true&isGaiaAvoided=false&voice_balance=1&drm_tech_type=2", playUrlV2, epid, qn, fnval)
-	client.HttpClient = &http.Client{}
-	request, err := client.newCookieRequest(http.MethodGet, url, nil)
+	return client.PlayUrlV2WithContext(context.Background(), epid, qn, fnval)
+}
+
+func (client *Client) PlayUrlV2WithContext(ctx context.Context, epid int64, qn Qn, fnval Fnval) (*PlayUrlV2Resp, error) {
+	url := fmt.Sprintf("%s?ep_id=%d&qn=%d&fnval=%d&fnver=0&fourk=1&support_multi_audio=true&gaia_source=&is_main_page=true&need_fragment=true&isGaiaAvoided=false&voice_balance=1&drm_tech_type=2", client.apiURL(playUrlV2Path), epid, qn, fnval)
+	request, err := client.newRequest(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 	request.Header.Add("referer", "https://www.bilibili.com/")
-	resp, err := client.HttpClient.Do(request)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.ErrUnexpectedStatusCode(resp.StatusCode)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
 	playUrlResp := &PlayUrlV2Resp{}
-	if err = json.Unmarshal(body, playUrlResp); err != nil {
+	if _, err = client.do(request, playUrlResp); err != nil {
 		return nil, err
 	}
-	if playUrlResp.Code != 0 {
-		return nil, errors.StatusError{Code: playUrlResp.Code, Cause: playUrlResp.Message}
-	}
 	return playUrlResp, nil
 }
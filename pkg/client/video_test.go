@@ -1,11 +1,17 @@
 package client
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/misssonder/bilibili/internal/util"
 	"github.com/misssonder/bilibili/pkg/video"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestClient_GetVideoInfo(t *testing.T) {
@@ -76,10 +82,36 @@ func TestClient_PlayUrlV2(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	resp, err := client.PlayUrlV2(epID, Qn4k, FnvalHDR|Fnval4K)
+	ep, err := strconv.ParseInt(epID, 10, 64)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp, err := client.PlayUrlV2(ep, Qn4k, FnvalHDR|Fnval4K)
 	if err != nil {
 		t.Error(err)
 		return
 	}
 	t.Log(util.MustMarshalIndent(resp))
 }
+
+func TestClient_GetVideoInfoWithContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := New(WithBaseURL(server.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.GetVideoInfoWithContext(ctx, "BV117411r7R1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMediaURLs(t *testing.T) {
+	durl := Durl{URL: "https://a/1.flv", BackupURL: []string{"https://b/1.flv", "", "https://a/1.flv"}}
+	assert.Equal(t, []string{"https://a/1.flv", "https://b/1.flv"}, durl.URLs())
+
+	media := DashMedia{BaseURL: "https://a/1.m4s"}
+	assert.Equal(t, []string{"https://a/1.m4s"}, media.URLs())
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMixinKey(t *testing.T) {
+	mixinKey := MixinKey(
+		"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",
+		"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png",
+	)
+	assert.Equal(t, "ea1db124af3c7062474693fa704f4ff8", mixinKey)
+}
+
+func TestSignWbiWithKey(t *testing.T) {
+	values := url.Values{}
+	values.Set("foo", "114")
+	values.Set("bar", "514")
+	values.Set("zab", "1919810")
+	signed := SignWbiWithKey(values, "ea1db124af3c7062474693fa704f4ff8", time.Unix(1702204169, 0))
+	assert.Equal(t, "1702204169", signed.Get("wts"))
+	assert.Equal(t, "8f6f2b5b3d485fe1886cec6a0be8c5d4", signed.Get("w_rid"))
+	assert.Empty(t, values.Get("w_rid"))
+}
+
+func TestClient_SignWbi(t *testing.T) {
+	var navCount int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case navInfoPath:
+			navCount++
+			_, _ = w.Write([]byte(`{"code":-101,"message":"账号未登录","data":{"isLogin":false,"wbi_img":{
+				"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",
+				"sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`))
+		case playUrlPath:
+			query := r.URL.Query()
+			wts, _ := strconv.ParseInt(query.Get("wts"), 10, 64)
+			query.Del("w_rid")
+			query.Del("wts")
+			expected := SignWbiWithKey(query, "ea1db124af3c7062474693fa704f4ff8", time.Unix(wts, 0))
+			if expected.Get("w_rid") != r.URL.Query().Get("w_rid") {
+				_, _ = w.Write([]byte(`{"code":-403,"message":"访问权限不足"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"code":0,"data":{"quality":80}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := New(WithBaseURL(server.URL))
+	for i := 0; i < 2; i++ {
+		resp, err := client.PlayUrl("BV117411r7R1", 1, Qn1080P, FnvalDash)
+		assert.NoError(t, err)
+		assert.Equal(t, 80, resp.Data.Quality)
+	}
+	assert.Equal(t, 1, navCount)
+
+	client.ResetWbiKey()
+	_, err := client.SignWbi(context.Background(), url.Values{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, navCount)
+}
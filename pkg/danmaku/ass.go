@@ -0,0 +1,213 @@
+package danmaku
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// ASSOptions controls how danmakus are laid out in WriteASS, zero values fall back to the defaults
+type ASSOptions struct {
+	// Width and Height are the play resolution, default is 1920x1080
+	Width  int
+	Height int
+	// FontName default is sans-serif
+	FontName string
+	// FontScale scales the font size, a normal danmaku is 50px high at 1080p when FontScale is 1
+	FontScale float64
+	// Opacity is between 0 (transparent) and 1, default is 0.8
+	Opacity float64
+	// ScrollDuration is how long a scrolling danmaku stays on screen, default is 8s
+	ScrollDuration time.Duration
+	// FixedDuration is how long a top or bottom danmaku stays on screen, default is 4s
+	FixedDuration time.Duration
+}
+
+func (options *ASSOptions) setDefaults() {
+	if options.Width <= 0 {
+		options.Width = 1920
+	}
+	if options.Height <= 0 {
+		options.Height = 1080
+	}
+	if len(options.FontName) == 0 {
+		options.FontName = "sans-serif"
+	}
+	if options.FontScale <= 0 {
+		options.FontScale = 1
+	}
+	if options.Opacity <= 0 || options.Opacity > 1 {
+		options.Opacity = 0.8
+	}
+	if options.ScrollDuration <= 0 {
+		options.ScrollDuration = 8 * time.Second
+	}
+	if options.FixedDuration <= 0 {
+		options.FixedDuration = 4 * time.Second
+	}
+}
+
+const danmakuASSHeader = `[Script Info]
+ScriptType: v4.00+
+PlayResX: %d
+PlayResY: %d
+WrapStyle: 2
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Danmaku,%s,%d,&H%02XFFFFFF,&H%02XFFFFFF,&H%02X000000,&H%02X000000,1,0,0,0,100,100,0,0,1,1,0,7,0,0,0,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+// lane is a row of the screen, end is when the last danmaku in it leaves and
+// enter is when its tail has fully entered the screen (scrolling only)
+type lane struct {
+	enter time.Duration
+	end   time.Duration
+}
+
+type layout struct {
+	options ASSOptions
+	size    int
+	scroll  []lane
+	top     []lane
+	bottom  []lane
+}
+
+// WriteASS renders scrolling, top and bottom danmakus as ASS dialogues,
+// advanced, code and BAS danmakus are skipped
+func WriteASS(w io.Writer, danmakus []Danmaku, options ASSOptions) error {
+	options.setDefaults()
+	sorted := make([]Danmaku, len(danmakus))
+	copy(sorted, danmakus)
+	Sort(sorted)
+
+	size := int(math.Round(50 * options.FontScale * float64(options.Height) / 1080))
+	rows := options.Height / size
+	if rows < 1 {
+		rows = 1
+	}
+	l := &layout{
+		options: options,
+		size:    size,
+		scroll:  make([]lane, rows),
+		top:     make([]lane, rows),
+		bottom:  make([]lane, rows),
+	}
+	alpha := int(math.Round((1 - options.Opacity) * 255))
+	writer := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(writer, danmakuASSHeader,
+		options.Width, options.Height, options.FontName, size, alpha, alpha, alpha, alpha)
+	for _, danmaku := range sorted {
+		dialogue, ok := l.place(danmaku)
+		if !ok {
+			continue
+		}
+		_, _ = writer.WriteString(dialogue)
+	}
+	return writer.Flush()
+}
+
+func (l *layout) fontSize(danmaku Danmaku) int {
+	if danmaku.FontSize <= 0 {
+		return l.size
+	}
+	return int(math.Round(float64(l.size) * float64(danmaku.FontSize) / 25))
+}
+
+func (l *layout) place(danmaku Danmaku) (string, bool) {
+	var (
+		size     = l.fontSize(danmaku)
+		width    = float64(runewidth.StringWidth(danmaku.Content)*size) / 2
+		start    = danmaku.Progress
+		end      time.Duration
+		override string
+	)
+	switch {
+	case danmaku.Mode.IsScroll() || danmaku.Mode == ModeReverseScroll:
+		end = start + l.options.ScrollDuration
+		speed := (float64(l.options.Width) + width) / l.options.ScrollDuration.Seconds()
+		row := chooseLane(l.scroll, func(lane lane) time.Duration {
+			// wait until the previous one has fully entered and will not be caught up
+			catchUp := lane.end - time.Duration(float64(l.options.Width)/speed*float64(time.Second))
+			if catchUp > lane.enter {
+				return catchUp
+			}
+			return lane.enter
+		}, start)
+		l.scroll[row] = lane{
+			enter: start + time.Duration(width/speed*float64(time.Second)),
+			end:   end,
+		}
+		y := row * l.size
+		if danmaku.Mode == ModeReverseScroll {
+			override = fmt.Sprintf(`\move(%d,%d,%d,%d)`, -int(width), y, l.options.Width, y)
+		} else {
+			override = fmt.Sprintf(`\move(%d,%d,%d,%d)`, l.options.Width, y, -int(width), y)
+		}
+	case danmaku.Mode == ModeTop:
+		end = start + l.options.FixedDuration
+		row := chooseLane(l.top, func(lane lane) time.Duration { return lane.end }, start)
+		l.top[row] = lane{end: end}
+		override = fmt.Sprintf(`\an8\pos(%d,%d)`, l.options.Width/2, row*l.size)
+	case danmaku.Mode == ModeBottom:
+		end = start + l.options.FixedDuration
+		row := chooseLane(l.bottom, func(lane lane) time.Duration { return lane.end }, start)
+		l.bottom[row] = lane{end: end}
+		override = fmt.Sprintf(`\an2\pos(%d,%d)`, l.options.Width/2, l.options.Height-row*l.size)
+	default:
+		return "", false
+	}
+	if size != l.size {
+		override += fmt.Sprintf(`\fs%d`, size)
+	}
+	if danmaku.Color&0xFFFFFF != 0xFFFFFF {
+		override += fmt.Sprintf(`\c&H%02X%02X%02X&`, danmaku.Color&0xFF, danmaku.Color>>8&0xFF, danmaku.Color>>16&0xFF)
+	}
+	return fmt.Sprintf("Dialogue: 0,%s,%s,Danmaku,,0,0,0,,{%s}%s\n",
+		assTimestamp(start), assTimestamp(end), override, escapeASS(danmaku.Content)), true
+}
+
+// chooseLane returns the first lane free at start, or the one that frees up earliest
+func chooseLane(lanes []lane, freeAt func(lane lane) time.Duration, start time.Duration) int {
+	best := 0
+	for i, lane := range lanes {
+		at := freeAt(lane)
+		if at <= start {
+			return i
+		}
+		if at < freeAt(lanes[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+var assEscaper = strings.NewReplacer(
+	`\`, `＼`,
+	"{", "｛",
+	"}", "｝",
+	"\r\n", `\N`,
+	"\n", `\N`,
+)
+
+func escapeASS(s string) string {
+	return assEscaper.Replace(s)
+}
+
+// assTimestamp formats d as h:mm:ss.cc
+func assTimestamp(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	cs := int64(d / (10 * time.Millisecond))
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
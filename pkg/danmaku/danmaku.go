@@ -0,0 +1,151 @@
+package danmaku
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode is where and how a danmaku moves
+type Mode int
+
+const (
+	ModeScroll        Mode = 1
+	ModeScroll2       Mode = 2
+	ModeScroll3       Mode = 3
+	ModeBottom        Mode = 4
+	ModeTop           Mode = 5
+	ModeReverseScroll Mode = 6
+	ModeAdvanced      Mode = 7
+	ModeCode          Mode = 8
+	ModeBAS           Mode = 9
+)
+
+// IsScroll reports whether the danmaku scrolls from right to left
+func (mode Mode) IsScroll() bool {
+	return mode == ModeScroll || mode == ModeScroll2 || mode == ModeScroll3
+}
+
+// Danmaku https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/docs/danmaku/danmaku_proto.md
+type Danmaku struct {
+	ID       int64
+	Progress time.Duration
+	Mode     Mode
+	FontSize int
+	// Color is 0xRRGGBB
+	Color   uint32
+	MidHash string
+	Content string
+	// Ctime is the unix time when the danmaku was sent
+	Ctime  int64
+	Weight int
+	Pool   int
+	IDStr  string
+	Attr   int
+}
+
+// Sort orders danmakus by progress
+func Sort(danmakus []Danmaku) {
+	sort.SliceStable(danmakus, func(i, j int) bool {
+		return danmakus[i].Progress < danmakus[j].Progress
+	})
+}
+
+type xmlDocument struct {
+	XMLName    xml.Name `xml:"i"`
+	ChatServer string   `xml:"chatserver"`
+	ChatID     int64    `xml:"chatid"`
+	Mission    int      `xml:"mission"`
+	MaxLimit   int      `xml:"maxlimit"`
+	State      int      `xml:"state"`
+	RealName   int      `xml:"real_name"`
+	Source     string   `xml:"source"`
+	D          []xmlD   `xml:"d"`
+}
+
+type xmlD struct {
+	P       string `xml:"p,attr"`
+	Content string `xml:",chardata"`
+}
+
+// ParseXML parses the legacy xml list, e.g. https://api.bilibili.com/x/v1/dm/list.so?oid={cid}
+func ParseXML(r io.Reader) ([]Danmaku, error) {
+	document := &xmlDocument{}
+	if err := xml.NewDecoder(r).Decode(document); err != nil {
+		return nil, err
+	}
+	danmakus := make([]Danmaku, 0, len(document.D))
+	for _, d := range document.D {
+		danmaku, err := parseXMLAttr(d.P)
+		if err != nil {
+			return nil, err
+		}
+		danmaku.Content = d.Content
+		danmakus = append(danmakus, danmaku)
+	}
+	Sort(danmakus)
+	return danmakus, nil
+}
+
+// parseXMLAttr parses p="progress,mode,fontsize,color,ctime,pool,midhash,id,weight"
+func parseXMLAttr(p string) (Danmaku, error) {
+	fields := strings.Split(p, ",")
+	if len(fields) < 8 {
+		return Danmaku{}, fmt.Errorf("invalid danmaku attribute: %s", p)
+	}
+	var (
+		danmaku = Danmaku{MidHash: fields[6], IDStr: fields[7]}
+		ints    = make([]int64, 0, 6)
+	)
+	progress, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Danmaku{}, fmt.Errorf("invalid danmaku attribute: %s", p)
+	}
+	for _, field := range []string{fields[1], fields[2], fields[3], fields[4], fields[5], fields[7]} {
+		i, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return Danmaku{}, fmt.Errorf("invalid danmaku attribute: %s", p)
+		}
+		ints = append(ints, i)
+	}
+	danmaku.Progress = time.Duration(progress * float64(time.Second))
+	danmaku.Mode = Mode(ints[0])
+	danmaku.FontSize = int(ints[1])
+	danmaku.Color = uint32(ints[2])
+	danmaku.Ctime = ints[3]
+	danmaku.Pool = int(ints[4])
+	danmaku.ID = ints[5]
+	if len(fields) > 8 {
+		danmaku.Weight, _ = strconv.Atoi(fields[8])
+	}
+	return danmaku, nil
+}
+
+// WriteXML writes danmakus in the legacy xml format of chatID (the cid)
+func WriteXML(w io.Writer, chatID int64, danmakus []Danmaku) error {
+	document := &xmlDocument{
+		ChatServer: "chat.bilibili.com",
+		ChatID:     chatID,
+		MaxLimit:   len(danmakus),
+		Source:     "k-v",
+		D:          make([]xmlD, 0, len(danmakus)),
+	}
+	for _, danmaku := range danmakus {
+		document.D = append(document.D, xmlD{
+			P: fmt.Sprintf("%.5f,%d,%d,%d,%d,%d,%s,%d,%d",
+				danmaku.Progress.Seconds(), danmaku.Mode, danmaku.FontSize, danmaku.Color,
+				danmaku.Ctime, danmaku.Pool, danmaku.MidHash, danmaku.ID, danmaku.Weight),
+			Content: danmaku.Content,
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(document)
+}
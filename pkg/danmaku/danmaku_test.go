@@ -0,0 +1,113 @@
+package danmaku
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testXML = `<?xml version="1.0" encoding="UTF-8"?><i><chatserver>chat.bilibili.com</chatserver><chatid>1176840</chatid><mission>0</mission><maxlimit>3000</maxlimit><state>0</state><real_name>0</real_name><source>k-v</source>
+<d p="13.21300,1,25,16777215,1665460318,0,5d5b5a8a,1176840000000000000,11">第二条</d>
+<d p="1.50000,5,25,16711680,1665460317,0,aaaa,1176839000000000000,5">第一条</d>
+</i>`
+
+func TestParseXML(t *testing.T) {
+	danmakus, err := ParseXML(strings.NewReader(testXML))
+	assert.NoError(t, err)
+	assert.Len(t, danmakus, 2)
+	assert.Equal(t, Danmaku{
+		ID:       1176839000000000000,
+		Progress: 1500 * time.Millisecond,
+		Mode:     ModeTop,
+		FontSize: 25,
+		Color:    0xFF0000,
+		MidHash:  "aaaa",
+		Content:  "第一条",
+		Ctime:    1665460317,
+		Weight:   5,
+		IDStr:    "1176839000000000000",
+	}, danmakus[0])
+
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, WriteXML(buffer, 1176840, danmakus))
+	again, err := ParseXML(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, danmakus, again)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+func appendField(b []byte, field int, v interface{}) []byte {
+	switch v := v.(type) {
+	case uint64:
+		b = appendUvarint(b, uint64(field<<3|wireVarint))
+		return appendUvarint(b, v)
+	case string:
+		b = appendUvarint(b, uint64(field<<3|wireBytes))
+		b = appendUvarint(b, uint64(len(v)))
+		return append(b, v...)
+	}
+	return b
+}
+
+func TestParseSegment(t *testing.T) {
+	var elem []byte
+	elem = appendField(elem, 1, uint64(42))
+	elem = appendField(elem, 2, uint64(61500))
+	elem = appendField(elem, 3, uint64(ModeBottom))
+	elem = appendField(elem, 4, uint64(36))
+	elem = appendField(elem, 5, uint64(0x00FF00))
+	elem = appendField(elem, 6, "hash")
+	elem = appendField(elem, 7, "弹幕")
+	elem = appendField(elem, 8, uint64(1665460317))
+	elem = appendField(elem, 10, "unknown")
+	elem = appendField(elem, 12, "42")
+	var reply []byte
+	reply = appendField(reply, 1, string(elem))
+	reply = appendField(reply, 1, string(appendField(nil, 2, uint64(1000))))
+
+	danmakus, err := ParseSegment(reply)
+	assert.NoError(t, err)
+	assert.Len(t, danmakus, 2)
+	assert.Equal(t, time.Second, danmakus[0].Progress)
+	assert.Equal(t, Danmaku{
+		ID:       42,
+		Progress: 61500 * time.Millisecond,
+		Mode:     ModeBottom,
+		FontSize: 36,
+		Color:    0x00FF00,
+		MidHash:  "hash",
+		Content:  "弹幕",
+		Ctime:    1665460317,
+		IDStr:    "42",
+	}, danmakus[1])
+
+	_, err = ParseSegment([]byte{0x0a, 0x05, 0x01})
+	assert.Error(t, err)
+}
+
+func TestWriteASS(t *testing.T) {
+	danmakus := []Danmaku{
+		{Progress: time.Second, Mode: ModeScroll, FontSize: 25, Color: 0xFFFFFF, Content: "one"},
+		{Progress: time.Second, Mode: ModeScroll, FontSize: 25, Color: 0xFFFFFF, Content: "two"},
+		{Progress: 2 * time.Second, Mode: ModeTop, FontSize: 25, Color: 0xFF0000, Content: "{top}"},
+		{Progress: 3 * time.Second, Mode: ModeBottom, FontSize: 36, Color: 0xFFFFFF, Content: "bottom"},
+		{Progress: 4 * time.Second, Mode: ModeAdvanced, Content: "[advanced]"},
+	}
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, WriteASS(buffer, danmakus, ASSOptions{}))
+	ass := buffer.String()
+	assert.Contains(t, ass, "PlayResX: 1920\nPlayResY: 1080\n")
+	assert.Contains(t, ass, "Dialogue: 0,0:00:01.00,0:00:09.00,Danmaku,,0,0,0,,{\\move(1920,0,-75,0)}one\n")
+	assert.Contains(t, ass, "Dialogue: 0,0:00:01.00,0:00:09.00,Danmaku,,0,0,0,,{\\move(1920,50,-75,50)}two\n")
+	assert.Contains(t, ass, "Dialogue: 0,0:00:02.00,0:00:06.00,Danmaku,,0,0,0,,{\\an8\\pos(960,0)\\c&H0000FF&}｛top｝\n")
+	assert.Contains(t, ass, "Dialogue: 0,0:00:03.00,0:00:07.00,Danmaku,,0,0,0,,{\\an2\\pos(960,1080)\\fs72}bottom\n")
+	assert.NotContains(t, ass, "advanced")
+}
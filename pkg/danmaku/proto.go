@@ -0,0 +1,127 @@
+package danmaku
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// ParseSegment parses the protobuf DmSegMobileReply returned by
+// https://api.bilibili.com/x/v2/dm/web/seg.so?type=1&oid={cid}&segment_index={n}
+//
+//	message DmSegMobileReply { repeated DanmakuElem elems = 1; }
+func ParseSegment(data []byte) ([]Danmaku, error) {
+	danmakus := make([]Danmaku, 0)
+	err := walk(data, func(field int, wireType int, v uint64, b []byte) error {
+		if field != 1 || wireType != wireBytes {
+			return nil
+		}
+		danmaku, err := parseElem(b)
+		if err != nil {
+			return err
+		}
+		danmakus = append(danmakus, danmaku)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	Sort(danmakus)
+	return danmakus, nil
+}
+
+// parseElem parses
+//
+//	message DanmakuElem {
+//	  int64 id = 1; int32 progress = 2; int32 mode = 3; int32 fontsize = 4; uint32 color = 5;
+//	  string midHash = 6; string content = 7; int64 ctime = 8; int32 weight = 9; string action = 10;
+//	  int32 pool = 11; string idStr = 12; int32 attr = 13;
+//	}
+func parseElem(data []byte) (Danmaku, error) {
+	danmaku := Danmaku{}
+	err := walk(data, func(field int, wireType int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			danmaku.ID = int64(v)
+		case 2:
+			danmaku.Progress = time.Duration(int32(v)) * time.Millisecond
+		case 3:
+			danmaku.Mode = Mode(int32(v))
+		case 4:
+			danmaku.FontSize = int(int32(v))
+		case 5:
+			danmaku.Color = uint32(v)
+		case 6:
+			danmaku.MidHash = string(b)
+		case 7:
+			danmaku.Content = string(b)
+		case 8:
+			danmaku.Ctime = int64(v)
+		case 9:
+			danmaku.Weight = int(int32(v))
+		case 11:
+			danmaku.Pool = int(int32(v))
+		case 12:
+			danmaku.IDStr = string(b)
+		case 13:
+			danmaku.Attr = int(int32(v))
+		}
+		return nil
+	})
+	return danmaku, err
+}
+
+// walk calls fn with every field of a protobuf message, v is set for numeric fields and b for length-delimited ones
+func walk(data []byte, fn func(field int, wireType int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("invalid protobuf key")
+		}
+		data = data[n:]
+		var (
+			field    = int(key >> 3)
+			wireType = int(key & 7)
+			v        uint64
+			b        []byte
+		)
+		switch wireType {
+		case wireVarint:
+			v, n = binary.Uvarint(data)
+			if n <= 0 {
+				return fmt.Errorf("invalid protobuf varint of field %d", field)
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return fmt.Errorf("invalid protobuf fixed64 of field %d", field)
+			}
+			v, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return fmt.Errorf("invalid protobuf bytes of field %d", field)
+			}
+			b, data = data[n:n+int(length)], data[n+int(length):]
+		case wireFixed32:
+			if len(data) < 4 {
+				return fmt.Errorf("invalid protobuf fixed32 of field %d", field)
+			}
+			v, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d of field %d", wireType, field)
+		}
+		if err := fn(field, wireType, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
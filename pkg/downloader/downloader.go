@@ -0,0 +1,491 @@
+package downloader
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/client"
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+const (
+	// PartSuffix is appended to the file name while it is being downloaded
+	PartSuffix = ".part"
+	// StateSuffix is appended to the file name of the sidecar state of a partial download
+	StateSuffix = ".part.json"
+
+	defaultReferer   = "https://www.bilibili.com"
+	defaultChunkSize = 4 << 20
+	defaultStall     = 30 * time.Second
+	bufferSize       = 32 * 1024
+)
+
+var (
+	// ErrChanged is returned when the remote file no longer matches the partial download
+	ErrChanged = stderrors.New("remote file changed")
+	// ErrStalled is returned when nothing is received within the stall timeout
+	ErrStalled = stderrors.New("download stalled")
+)
+
+// Progress is notified of the download progress of a file
+type Progress interface {
+	// Start is called before every attempt with the total size (-1 if unknown) and the bytes already downloaded
+	Start(total, completed int64)
+	// Add is called with every chunk written
+	Add(n int)
+}
+
+// Downloader downloads media urls into files, the partial file and its sidecar state are kept
+// on failure so that a later download with resume can continue with Range requests.
+// With more than one connection the file is split into chunks fetched concurrently.
+type Downloader struct {
+	httpClient  *http.Client
+	header      http.Header
+	retryPolicy client.RetryPolicy
+	resume      bool
+	connections int
+	chunkSize   int64
+	stall       time.Duration
+	probe       bool
+}
+
+// Option configures a Downloader created by New
+type Option func(downloader *Downloader)
+
+// WithHttpClient replaces the http client used for media requests
+func WithHttpClient(httpClient *http.Client) Option {
+	return func(downloader *Downloader) {
+		downloader.httpClient = httpClient
+	}
+}
+
+// WithHeader sets a header of every media request, the referer is https://www.bilibili.com by default
+func WithHeader(key, value string) Option {
+	return func(downloader *Downloader) {
+		downloader.header.Set(key, value)
+	}
+}
+
+// WithRetryPolicy decides how interrupted downloads are retried, default is 3 retries with exponential backoff
+func WithRetryPolicy(policy client.RetryPolicy) Option {
+	return func(downloader *Downloader) {
+		downloader.retryPolicy = policy
+	}
+}
+
+// WithResume continues from the partial file left by a previous download instead of starting over
+func WithResume(resume bool) Option {
+	return func(downloader *Downloader) {
+		downloader.resume = resume
+	}
+}
+
+// WithConnections sets how many ranges of a file are fetched at the same time, default is 1
+func WithConnections(connections int) Option {
+	return func(downloader *Downloader) {
+		if connections > 0 {
+			downloader.connections = connections
+		}
+	}
+}
+
+// WithChunkSize sets the size of the ranges fetched by the connections, default is 4MiB
+func WithChunkSize(chunkSize int64) Option {
+	return func(downloader *Downloader) {
+		if chunkSize > 0 {
+			downloader.chunkSize = chunkSize
+		}
+	}
+}
+
+// WithStallTimeout gives up a connection that receives nothing for timeout and tries the next mirror,
+// default is 30s and 0 disables it
+func WithStallTimeout(timeout time.Duration) Option {
+	return func(downloader *Downloader) {
+		downloader.stall = timeout
+	}
+}
+
+// WithProbe ranks the mirrors of a file by fetching a small range from each of them before downloading
+func WithProbe(probe bool) Option {
+	return func(downloader *Downloader) {
+		downloader.probe = probe
+	}
+}
+
+// New returns a Downloader
+func New(options ...Option) *Downloader {
+	backoff := client.NewExponentialBackoff(3)
+	backoff.RetryIf = Retryable
+	downloader := &Downloader{
+		httpClient:  &http.Client{},
+		header:      http.Header{},
+		retryPolicy: backoff,
+		connections: 1,
+		chunkSize:   defaultChunkSize,
+		stall:       defaultStall,
+	}
+	downloader.header.Set("Referer", defaultReferer)
+	for _, option := range options {
+		option(downloader)
+	}
+	return downloader
+}
+
+// Retryable reports whether an interrupted download is worth retrying,
+// besides client.Retryable a connection closed before the end or stalled is retried
+func Retryable(err error) bool {
+	return stderrors.Is(err, io.ErrUnexpectedEOF) || stderrors.Is(err, ErrStalled) || client.Retryable(err)
+}
+
+// state is the sidecar of a partial download, a sequential download has no chunks
+// and its progress is the size of the partial file
+type state struct {
+	Size         int64   `json:"size"`
+	ETag         string  `json:"etag,omitempty"`
+	LastModified string  `json:"last_modified,omitempty"`
+	Chunks       []chunk `json:"chunks,omitempty"`
+}
+
+// validator is sent as If-Range so that a changed file is sent in full
+func (s *state) validator() string {
+	if len(s.ETag) > 0 {
+		return s.ETag
+	}
+	return s.LastModified
+}
+
+// Download downloads url into file, progress may be nil
+func (downloader *Downloader) Download(ctx context.Context, url, file string, progress Progress) error {
+	return downloader.DownloadURLs(ctx, []string{url}, file, progress)
+}
+
+// DownloadURLs downloads file from the mirrors in urls, e.g. the base url and the backup urls of a stream.
+// The next mirror is tried on connection errors, 403s and stalls.
+func (downloader *Downloader) DownloadURLs(ctx context.Context, urls []string, file string, progress Progress) error {
+	if len(urls) == 0 {
+		return stderrors.New("no url to download")
+	}
+	if progress == nil {
+		progress = nopProgress{}
+	}
+	if downloader.probe && len(urls) > 1 {
+		urls = downloader.rank(ctx, urls)
+	}
+	t := &task{
+		downloader: downloader,
+		urls:       urls,
+		partFile:   file + PartSuffix,
+		stateFile:  file + StateSuffix,
+		progress:   progress,
+	}
+	if err := t.open(); err != nil {
+		return err
+	}
+	err := t.start(ctx)
+	if stderrors.Is(err, ErrChanged) && t.segmented() {
+		// the chunks fetched so far belong to another file, start over once
+		if err = t.reset(); err == nil {
+			err = t.start(ctx)
+		}
+	}
+	if closeErr := t.out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if t.state == nil {
+			// nothing worth resuming was received
+			_ = os.Remove(t.partFile)
+		}
+		return err
+	}
+	if err = os.Rename(t.partFile, file); err != nil {
+		return err
+	}
+	_ = os.Remove(t.stateFile)
+	return nil
+}
+
+type task struct {
+	downloader *Downloader
+	urls       []string
+	partFile   string
+	stateFile  string
+	progress   Progress
+
+	out    *os.File
+	state  *state
+	offset int64
+	// mirror is the index of the url in use
+	mirror int
+	// mu guards the chunks of state and mirror
+	mu sync.Mutex
+}
+
+// open opens the partial file, its content is kept only when resuming from a valid state
+func (t *task) open() error {
+	var err error
+	if t.downloader.resume {
+		t.state, err = loadState(t.stateFile)
+		if err != nil {
+			return err
+		}
+	}
+	t.out, err = os.OpenFile(t.partFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if t.segmented() {
+		info, err := t.out.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() == t.state.Size {
+			// the chunks of a segmented download know their own progress
+			return nil
+		}
+		// the partial file was deleted or truncated, the chunks marked done are lost
+		t.state = nil
+		_ = os.Remove(t.stateFile)
+	}
+	if t.state != nil {
+		info, err := t.out.Stat()
+		if err != nil {
+			return err
+		}
+		t.offset = info.Size()
+		if t.state.Size >= 0 && t.offset > t.state.Size {
+			t.offset = 0
+		}
+	}
+	return t.out.Truncate(t.offset)
+}
+
+// start continues a segmented download, or splits a new one into chunks when the server supports ranges
+func (t *task) start(ctx context.Context) error {
+	if !t.segmented() && t.state == nil && t.downloader.connections > 1 {
+		if s := t.probe(ctx); s != nil && s.Size > t.downloader.chunkSize {
+			s.Chunks = split(s.Size, t.downloader.chunkSize)
+			if err := t.out.Truncate(s.Size); err != nil {
+				return err
+			}
+			if err := saveState(t.stateFile, s); err != nil {
+				return err
+			}
+			t.state = s
+		}
+	}
+	if t.segmented() {
+		return t.runChunks(ctx)
+	}
+	return t.retry(ctx, func(url string) (http.Header, bool, error) {
+		before := t.offset
+		header, err := t.fetch(ctx, url)
+		return header, t.offset > before, err
+	})
+}
+
+func (t *task) segmented() bool {
+	return t.state != nil && len(t.state.Chunks) > 0
+}
+
+// retry calls fetch with the mirror in use until it succeeds or the retry policy gives up.
+// The other mirrors are tried at once before an attempt is counted, and the attempts are
+// counted again once fetch makes progress.
+func (t *task) retry(ctx context.Context, fetch func(url string) (http.Header, bool, error)) error {
+	failovers := 0
+	for attempt := 1; ; attempt++ {
+		url := t.currentURL()
+		header, progressed, err := fetch(url)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if progressed {
+			attempt, failovers = 1, 0
+		}
+		if failover(err) {
+			t.nextURL(url)
+			if failovers < len(t.urls)-1 {
+				failovers++
+				attempt--
+				continue
+			}
+		}
+		delay, retry := t.downloader.retryPolicy.Backoff(attempt, header, err)
+		if !retry {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (t *task) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range t.downloader.header {
+		request.Header[key] = values
+	}
+	return request, nil
+}
+
+// fetch requests the rest of the file from offset and writes it into the partial file
+func (t *task) fetch(ctx context.Context, url string) (http.Header, error) {
+	ctx, dog, stop := watch(ctx, t.downloader.stall)
+	defer stop()
+	header, err := t.fetchBody(ctx, url, dog)
+	return header, dog.err(err)
+}
+
+func (t *task) fetchBody(ctx context.Context, url string, dog *watchdog) (http.Header, error) {
+	request, err := t.newRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if t.offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", t.offset))
+		if validator := t.state.validator(); len(validator) > 0 {
+			request.Header.Set("If-Range", validator)
+		}
+	}
+	resp, err := t.downloader.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	size := resp.ContentLength
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// a full body, either the first request or the file has changed
+		if err = t.reset(); err != nil {
+			return resp.Header, err
+		}
+	case http.StatusPartialContent:
+		start, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return resp.Header, err
+		}
+		if start != t.offset || (t.state.Size >= 0 && total != t.state.Size) || changed(t.state, resp.Header) {
+			return t.restart(ctx, url, dog)
+		}
+		size = total
+	case http.StatusRequestedRangeNotSatisfiable:
+		if t.state != nil && t.state.Size == t.offset {
+			return resp.Header, nil
+		}
+		return t.restart(ctx, url, dog)
+	default:
+		return resp.Header, errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+	if t.offset == 0 {
+		t.state = &state{
+			Size:         size,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+		if err = saveState(t.stateFile, t.state); err != nil {
+			return resp.Header, err
+		}
+	}
+	t.progress.Start(t.state.Size, t.offset)
+	if err = t.copy(dog.reader(resp.Body)); err != nil {
+		return resp.Header, err
+	}
+	if t.state.Size >= 0 && t.offset != t.state.Size {
+		return resp.Header, io.ErrUnexpectedEOF
+	}
+	return resp.Header, nil
+}
+
+func (t *task) copy(body io.Reader) error {
+	if _, err := t.out.Seek(t.offset, io.SeekStart); err != nil {
+		return err
+	}
+	buf := make([]byte, bufferSize)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, err := t.out.Write(buf[:n]); err != nil {
+				return err
+			}
+			t.offset += int64(n)
+			t.progress.Add(n)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// restart fetches the whole file again when the partial one does not match the remote file
+func (t *task) restart(ctx context.Context, url string, dog *watchdog) (http.Header, error) {
+	if err := t.reset(); err != nil {
+		return nil, err
+	}
+	return t.fetchBody(ctx, url, dog)
+}
+
+// reset drops the downloaded content and state
+func (t *task) reset() error {
+	t.offset = 0
+	t.state = nil
+	return t.out.Truncate(0)
+}
+
+// changed reports whether the validators of a 206 response differ from the saved ones
+func changed(s *state, header http.Header) bool {
+	if etag := header.Get("ETag"); len(etag) > 0 && len(s.ETag) > 0 {
+		return etag != s.ETag
+	}
+	if lastModified := header.Get("Last-Modified"); len(lastModified) > 0 && len(s.LastModified) > 0 {
+		return lastModified != s.LastModified
+	}
+	return false
+}
+
+// parseContentRange parses "bytes start-end/total", total is -1 when it is "*"
+func parseContentRange(value string) (start, total int64, err error) {
+	invalid := fmt.Errorf("invalid Content-Range: %s", value)
+	value = strings.TrimPrefix(value, "bytes ")
+	slash := strings.Index(value, "/")
+	dash := strings.Index(value, "-")
+	if slash < 0 || dash < 0 || dash > slash {
+		return 0, 0, invalid
+	}
+	if start, err = strconv.ParseInt(value[:dash], 10, 64); err != nil {
+		return 0, 0, invalid
+	}
+	if value[slash+1:] == "*" {
+		return start, -1, nil
+	}
+	if total, err = strconv.ParseInt(value[slash+1:], 10, 64); err != nil {
+		return 0, 0, invalid
+	}
+	return start, total, nil
+}
+
+type nopProgress struct{}
+
+func (nopProgress) Start(int64, int64) {}
+
+func (nopProgress) Add(int) {}
@@ -0,0 +1,140 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+var content = bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+
+func serveContent(etag string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "media.m4s", time.Time{}, bytes.NewReader(content))
+	}
+}
+
+func fastRetry() Option {
+	backoff := client.NewExponentialBackoff(3)
+	backoff.BaseDelay = time.Millisecond
+	backoff.RetryIf = Retryable
+	return WithRetryPolicy(backoff)
+}
+
+func TestDownload(t *testing.T) {
+	var referer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		referer = r.Header.Get("Referer")
+		serveContent(`"v1"`)(w, r)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "video.mp4")
+	err := New().Download(context.Background(), server.URL, file, nil)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, defaultReferer, referer)
+	assert.NoFileExists(t, file+PartSuffix)
+	assert.NoFileExists(t, file+StateSuffix)
+}
+
+func TestDownloadRetryWithRange(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// send half of the file and drop the connection
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Length", "1048576")
+			_, _ = w.Write(content[:len(content)/2])
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+		assert.Equal(t, "bytes=524288-", r.Header.Get("Range"))
+		assert.Equal(t, `"v1"`, r.Header.Get("If-Range"))
+		serveContent(`"v1"`)(w, r)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "video.mp4")
+	err := New(fastRetry()).Download(context.Background(), server.URL, file, nil)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestDownloadResume(t *testing.T) {
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		serveContent(`"v1"`)(w, r)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "video.mp4")
+	assert.NoError(t, os.WriteFile(file+PartSuffix, content[:1000], 0644))
+	assert.NoError(t, saveState(file+StateSuffix, &state{Size: int64(len(content)), ETag: `"v1"`}))
+
+	err := New(WithResume(true)).Download(context.Background(), server.URL, file, nil)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, []string{"bytes=1000-"}, ranges)
+}
+
+func TestDownloadResumeChanged(t *testing.T) {
+	server := httptest.NewServer(serveContent(`"v2"`))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "video.mp4")
+	assert.NoError(t, os.WriteFile(file+PartSuffix, bytes.Repeat([]byte("x"), 1000), 0644))
+	assert.NoError(t, saveState(file+StateSuffix, &state{Size: int64(len(content)), ETag: `"v1"`}))
+
+	err := New(WithResume(true)).Download(context.Background(), server.URL, file, nil)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func TestDownloadUnexpectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "video.mp4")
+	err := New().Download(context.Background(), server.URL, file, nil)
+	assert.Error(t, err)
+	assert.NoFileExists(t, file)
+	assert.NoFileExists(t, file+PartSuffix)
+}
+
+func TestParseContentRange(t *testing.T) {
+	start, total, err := parseContentRange("bytes 100-199/1000")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), start)
+	assert.Equal(t, int64(1000), total)
+
+	_, total, err = parseContentRange("bytes 0-99/*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), total)
+
+	_, _, err = parseContentRange("bytes */1000")
+	assert.Error(t, err)
+}
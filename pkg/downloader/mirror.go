@@ -0,0 +1,161 @@
+package downloader
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+const (
+	probeSize    = 256 * 1024
+	probeTimeout = 5 * time.Second
+)
+
+func (t *task) currentURL() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.urls[t.mirror]
+}
+
+// nextURL switches to the mirror after failed, unless another connection has switched already
+func (t *task) nextURL(failed string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.urls[t.mirror] == failed {
+		t.mirror = (t.mirror + 1) % len(t.urls)
+	}
+}
+
+// failover reports whether another mirror may succeed where one failed with err
+func failover(err error) bool {
+	var statusCode errors.ErrUnexpectedStatusCode
+	if stderrors.As(err, &statusCode) {
+		return statusCode == http.StatusForbidden ||
+			statusCode == http.StatusNotFound ||
+			statusCode == http.StatusTooManyRequests ||
+			statusCode >= http.StatusInternalServerError
+	}
+	return Retryable(err)
+}
+
+// rank orders urls by how fast they send the first bytes, the failed ones go last
+func (downloader *Downloader) rank(ctx context.Context, urls []string) []string {
+	var (
+		elapsed = make([]time.Duration, len(urls))
+		wg      sync.WaitGroup
+	)
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			start := time.Now()
+			if err := downloader.probeURL(ctx, url); err != nil {
+				elapsed[i] = probeTimeout * 2
+				return
+			}
+			elapsed[i] = time.Since(start)
+		}(i, url)
+	}
+	wg.Wait()
+	indexes := make([]int, len(urls))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return elapsed[indexes[i]] < elapsed[indexes[j]]
+	})
+	ranked := make([]string, 0, len(urls))
+	for _, i := range indexes {
+		ranked = append(ranked, urls[i])
+	}
+	return ranked
+}
+
+func (downloader *Downloader) probeURL(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range downloader.header {
+		request.Header[key] = values
+	}
+	request.Header.Set("Range", fmt.Sprintf("bytes=0-%d", probeSize-1))
+	resp, err := downloader.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+	_, err = io.Copy(io.Discard, io.LimitReader(resp.Body, probeSize))
+	return err
+}
+
+// watchdog cancels a request when nothing is received within the timeout
+type watchdog struct {
+	timer   *time.Timer
+	timeout time.Duration
+	stalled int32
+}
+
+// watch returns a context canceled by the watchdog on stalls and a func to stop watching,
+// a timeout of 0 never stalls
+func watch(ctx context.Context, timeout time.Duration) (context.Context, *watchdog, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	dog := &watchdog{timeout: timeout}
+	if timeout > 0 {
+		dog.timer = time.AfterFunc(timeout, func() {
+			atomic.StoreInt32(&dog.stalled, 1)
+			cancel()
+		})
+	}
+	return ctx, dog, func() {
+		if dog.timer != nil {
+			dog.timer.Stop()
+		}
+		cancel()
+	}
+}
+
+func (dog *watchdog) touch() {
+	if dog.timer != nil {
+		dog.timer.Reset(dog.timeout)
+	}
+}
+
+// err replaces the error caused by a stall with ErrStalled
+func (dog *watchdog) err(err error) error {
+	if err != nil && atomic.LoadInt32(&dog.stalled) == 1 {
+		return ErrStalled
+	}
+	return err
+}
+
+// reader touches the watchdog whenever r reads something
+func (dog *watchdog) reader(r io.Reader) io.Reader {
+	return &watchedReader{r: r, dog: dog}
+}
+
+type watchedReader struct {
+	r   io.Reader
+	dog *watchdog
+}
+
+func (r *watchedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.dog.touch()
+	}
+	return n, err
+}
@@ -0,0 +1,87 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadFailover(t *testing.T) {
+	var forbidden int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&forbidden, 1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(serveContent(`"v1"`))
+	defer good.Close()
+
+	file := filepath.Join(t.TempDir(), "video.m4s")
+	err := New().DownloadURLs(context.Background(), []string{bad.URL, good.URL}, file, nil)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&forbidden))
+}
+
+func TestDownloadFailoverAllForbidden(t *testing.T) {
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer bad.Close()
+
+	file := filepath.Join(t.TempDir(), "video.m4s")
+	err := New(fastRetry()).DownloadURLs(context.Background(), []string{bad.URL, bad.URL + "/backup"}, file, nil)
+	assert.ErrorIs(t, err, errors.ErrUnexpectedStatusCode(http.StatusForbidden))
+}
+
+func TestDownloadFailoverStalled(t *testing.T) {
+	release := make(chan struct{})
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1048576")
+		_, _ = w.Write(content[:1024])
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer stalled.Close()
+	defer close(release)
+	good := httptest.NewServer(serveContent(`"v1"`))
+	defer good.Close()
+
+	file := filepath.Join(t.TempDir(), "video.m4s")
+	err := New(WithStallTimeout(100*time.Millisecond)).
+		DownloadURLs(context.Background(), []string{stalled.URL, good.URL}, file, nil)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func TestRank(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		serveContent(`"v1"`)(w, r)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(serveContent(`"v1"`))
+	defer fast.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer bad.Close()
+
+	ranked := New().rank(context.Background(), []string{bad.URL, slow.URL, fast.URL})
+	assert.Equal(t, []string{fast.URL, slow.URL, bad.URL}, ranked)
+}
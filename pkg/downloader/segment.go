@@ -0,0 +1,199 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/misssonder/bilibili/pkg/errors"
+)
+
+// chunk is the byte range [Start, End] of a segmented download, Done bytes of it are written
+type chunk struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Done  int64 `json:"done"`
+}
+
+func (c chunk) size() int64 {
+	return c.End - c.Start + 1
+}
+
+// split cuts size bytes into chunks of chunkSize
+func split(size, chunkSize int64) []chunk {
+	chunks := make([]chunk, 0, (size+chunkSize-1)/chunkSize)
+	for start := int64(0); start < size; start += chunkSize {
+		end := start + chunkSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		chunks = append(chunks, chunk{Start: start, End: end})
+	}
+	return chunks
+}
+
+// probe requests the first byte to learn the size and validators of the file,
+// nil is returned if the server does not support ranges
+func (t *task) probe(ctx context.Context) *state {
+	request, err := t.newRequest(ctx, t.currentURL())
+	if err != nil {
+		return nil
+	}
+	request.Header.Set("Range", "bytes=0-0")
+	resp, err := t.downloader.httpClient.Do(request)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil
+	}
+	_, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil || total <= 0 {
+		return nil
+	}
+	return &state{
+		Size:         total,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+}
+
+// runChunks fetches the unfinished chunks with the connections of the downloader,
+// the state is saved whenever a chunk finishes and when the download stops
+func (t *task) runChunks(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		pending   = make(chan int, len(t.state.Chunks))
+		completed int64
+		wg        sync.WaitGroup
+		once      sync.Once
+		firstErr  error
+	)
+	for i, c := range t.state.Chunks {
+		completed += c.Done
+		if c.Done < c.size() {
+			pending <- i
+		}
+	}
+	close(pending)
+	t.progress.Start(t.state.Size, completed)
+
+	for i := 0; i < t.downloader.connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				err := t.retry(ctx, func(url string) (http.Header, bool, error) {
+					before := t.chunkDone(i)
+					header, err := t.fetchChunk(ctx, url, i)
+					return header, t.chunkDone(i) > before, err
+				})
+				if err == nil {
+					err = t.saveChunks()
+				}
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := t.saveChunks(); firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+func (t *task) chunkDone(i int) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state.Chunks[i].Done
+}
+
+func (t *task) saveChunks() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return saveState(t.stateFile, t.state)
+}
+
+// fetchChunk requests the rest of the i-th chunk and writes it at its offset of the partial file
+func (t *task) fetchChunk(ctx context.Context, url string, i int) (http.Header, error) {
+	ctx, dog, stop := watch(ctx, t.downloader.stall)
+	defer stop()
+	header, err := t.fetchChunkBody(ctx, url, i, dog)
+	return header, dog.err(err)
+}
+
+func (t *task) fetchChunkBody(ctx context.Context, url string, i int, dog *watchdog) (http.Header, error) {
+	t.mu.Lock()
+	c := t.state.Chunks[i]
+	t.mu.Unlock()
+	from := c.Start + c.Done
+
+	request, err := t.newRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, c.End))
+	if validator := t.state.validator(); len(validator) > 0 {
+		request.Header.Set("If-Range", validator)
+	}
+	resp, err := t.downloader.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the range was ignored because the file has changed
+		return resp.Header, ErrChanged
+	default:
+		return resp.Header, errors.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+	start, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return resp.Header, err
+	}
+	if start != from || total != t.state.Size || changed(t.state, resp.Header) {
+		return resp.Header, ErrChanged
+	}
+
+	var (
+		body   = io.LimitReader(dog.reader(resp.Body), c.End-from+1)
+		buf    = make([]byte, bufferSize)
+		offset = from
+	)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, err := t.out.WriteAt(buf[:n], offset); err != nil {
+				return resp.Header, err
+			}
+			offset += int64(n)
+			t.mu.Lock()
+			t.state.Chunks[i].Done += int64(n)
+			t.mu.Unlock()
+			t.progress.Add(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return resp.Header, err
+		}
+	}
+	if offset != c.End+1 {
+		return resp.Header, io.ErrUnexpectedEOF
+	}
+	return resp.Header, nil
+}
@@ -0,0 +1,145 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countProgress struct {
+	mu        sync.Mutex
+	total     int64
+	completed int64
+}
+
+func (p *countProgress) Start(total, completed int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total, p.completed = total, completed
+}
+
+func (p *countProgress) Add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completed += int64(n)
+}
+
+func TestDownloadSegmented(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		ranges = make(map[string]bool)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges[r.Header.Get("Range")] = true
+		mu.Unlock()
+		serveContent(`"v1"`)(w, r)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "video.m4s")
+	progress := &countProgress{}
+	err := New(WithConnections(4), WithChunkSize(256*1024)).Download(context.Background(), server.URL, file, progress)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, int64(len(content)), progress.total)
+	assert.Equal(t, int64(len(content)), progress.completed)
+	assert.True(t, ranges["bytes=0-0"])
+	assert.True(t, ranges["bytes=0-262143"])
+	assert.True(t, ranges["bytes=786432-1048575"])
+	assert.Len(t, ranges, 5)
+}
+
+func TestDownloadSegmentedResume(t *testing.T) {
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		serveContent(`"v1"`)(w, r)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "video.m4s")
+	size := int64(len(content))
+	partial := make([]byte, size)
+	copy(partial, content[:size/2+100])
+	assert.NoError(t, os.WriteFile(file+PartSuffix, partial, 0644))
+	chunks := split(size, size/2)
+	chunks[0].Done = chunks[0].size()
+	chunks[1].Done = 100
+	assert.NoError(t, saveState(file+StateSuffix, &state{Size: size, ETag: `"v1"`, Chunks: chunks}))
+
+	err := New(WithResume(true), WithConnections(2)).Download(context.Background(), server.URL, file, nil)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, []string{"bytes=524388-1048575"}, ranges)
+}
+
+func TestDownloadSegmentedLostPart(t *testing.T) {
+	server := httptest.NewServer(serveContent(`"v1"`))
+	defer server.Close()
+
+	size := int64(len(content))
+	for _, partial := range [][]byte{nil, content[:size/2]} {
+		file := filepath.Join(t.TempDir(), "video.m4s")
+		// the state survived but the partial file was deleted or truncated
+		if partial != nil {
+			assert.NoError(t, os.WriteFile(file+PartSuffix, partial, 0644))
+		}
+		chunks := split(size, size/2)
+		chunks[0].Done = chunks[0].size()
+		assert.NoError(t, saveState(file+StateSuffix, &state{Size: size, ETag: `"v1"`, Chunks: chunks}))
+
+		err := New(WithResume(true), WithConnections(2), WithChunkSize(size/4)).Download(context.Background(), server.URL, file, nil)
+		assert.NoError(t, err)
+		data, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, content, data)
+	}
+}
+
+func TestDownloadSegmentedChanged(t *testing.T) {
+	server := httptest.NewServer(serveContent(`"v2"`))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "video.m4s")
+	size := int64(len(content))
+	assert.NoError(t, os.WriteFile(file+PartSuffix, make([]byte, size), 0644))
+	chunks := split(size, size/2)
+	chunks[0].Done = chunks[0].size()
+	assert.NoError(t, saveState(file+StateSuffix, &state{Size: size, ETag: `"v1"`, Chunks: chunks}))
+
+	err := New(WithResume(true), WithConnections(2), WithChunkSize(size/4)).Download(context.Background(), server.URL, file, nil)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func TestDownloadSegmentedWithoutRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "video.m4s")
+	err := New(WithConnections(4), WithChunkSize(1024)).Download(context.Background(), server.URL, file, nil)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func TestSplit(t *testing.T) {
+	assert.Equal(t, []chunk{{Start: 0, End: 3}, {Start: 4, End: 7}, {Start: 8, End: 9}}, split(10, 4))
+	assert.Equal(t, []chunk{{Start: 0, End: 7}}, split(8, 8))
+}
@@ -0,0 +1,31 @@
+package downloader
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// loadState returns nil without error when there is no state to resume from
+func loadState(file string) (*state, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &state{}
+	if err = json.Unmarshal(data, s); err != nil {
+		// a broken state is not worth failing for, start over
+		return nil, nil
+	}
+	return s, nil
+}
+
+func saveState(file string, s *state) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
@@ -0,0 +1,39 @@
+package downloader
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Verify checks the size and the md5 (hex) of file, a size <= 0 or an empty md5 is not checked
+func Verify(file string, size int64, md5sum string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if size > 0 {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() != size {
+			return fmt.Errorf("size of %s is %d, want %d", file, info.Size(), size)
+		}
+	}
+	if len(md5sum) == 0 {
+		return nil
+	}
+	hash := md5.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, md5sum) {
+		return fmt.Errorf("md5 of %s is %s, want %s", file, sum, md5sum)
+	}
+	return nil
+}
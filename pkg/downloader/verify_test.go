@@ -0,0 +1,19 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "1.flv")
+	assert.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	assert.NoError(t, Verify(file, 5, "5d41402abc4b2a76b9719d911017c592"))
+	assert.NoError(t, Verify(file, 0, ""))
+	assert.Error(t, Verify(file, 6, ""))
+	assert.Error(t, Verify(file, 5, "00000000000000000000000000000000"))
+}
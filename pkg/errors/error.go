@@ -2,15 +2,67 @@ package errors
 
 import "fmt"
 
+// Known bilibili api codes, https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/docs/misc/errcode.md
+const (
+	CodeNotLogin           = -101
+	CodeAccountBanned      = -102
+	CodeCsrfFailed         = -111
+	CodeBadRequest         = -400
+	CodeAccessDenied       = -403
+	CodeNotFound           = -404
+	CodeRequestIntercepted = -412
+	CodeRiskControl        = -352
+	CodeTooManyRequests    = -509
+	CodeRegionLocked       = -10403
+	CodeVideoInvisible     = 62002
+	CodeVideoUnderReview   = 62004
+	CodeVipRequired        = 6002003
+)
+
+// Sentinel errors of known codes, match them with errors.Is
+var (
+	ErrNotLogin           = StatusError{Code: CodeNotLogin, Cause: "not logged in"}
+	ErrAccountBanned      = StatusError{Code: CodeAccountBanned, Cause: "account banned"}
+	ErrCsrfFailed         = StatusError{Code: CodeCsrfFailed, Cause: "csrf check failed"}
+	ErrBadRequest         = StatusError{Code: CodeBadRequest, Cause: "bad request"}
+	ErrAccessDenied       = StatusError{Code: CodeAccessDenied, Cause: "access denied"}
+	ErrNotFound           = StatusError{Code: CodeNotFound, Cause: "not found"}
+	ErrRequestIntercepted = StatusError{Code: CodeRequestIntercepted, Cause: "request intercepted"}
+	ErrRiskControl        = StatusError{Code: CodeRiskControl, Cause: "risk control triggered"}
+	ErrTooManyRequests    = StatusError{Code: CodeTooManyRequests, Cause: "too many requests"}
+	ErrRegionLocked       = StatusError{Code: CodeRegionLocked, Cause: "not available in your region"}
+	ErrVideoInvisible     = StatusError{Code: CodeVideoInvisible, Cause: "video invisible"}
+	ErrVideoUnderReview   = StatusError{Code: CodeVideoUnderReview, Cause: "video under review"}
+	ErrVipRequired        = StatusError{Code: CodeVipRequired, Cause: "vip required"}
+)
+
+// StatusError is returned when the api responds with a non-zero code
 type StatusError struct {
 	Code  int
 	Cause string
 }
 
+// New returns a StatusError of code
+func New(code int, cause string) error {
+	return StatusError{Code: code, Cause: cause}
+}
+
 func (err StatusError) Error() string {
 	return fmt.Sprintf("unexpected status code: %d, cause: %s", err.Code, err.Cause)
 }
 
+// Is reports whether target is a StatusError with the same code
+func (err StatusError) Is(target error) bool {
+	switch t := target.(type) {
+	case StatusError:
+		return t.Code == err.Code
+	case *StatusError:
+		return t != nil && t.Code == err.Code
+	default:
+		return false
+	}
+}
+
 // ErrUnexpectedStatusCode is returned on unexpected HTTP status codes
 type ErrUnexpectedStatusCode int
 
@@ -0,0 +1,77 @@
+// Package filename makes file names safe on Linux, Windows and macOS.
+package filename
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxBytes is the length limit of a file name on most filesystems
+const MaxBytes = 255
+
+// replacement takes the place of the characters not allowed in a file name
+const replacement = '_'
+
+// reserved are the device names of Windows, they are reserved with any extension
+var reserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Sanitize returns name as a single path element that is valid on Linux, Windows and macOS and is
+// at most maxBytes long, maxBytes <= 0 means MaxBytes. Separators, the characters reserved by Windows
+// and control characters are replaced, invalid UTF-8 is replaced, leading spaces and trailing dots
+// and spaces are trimmed and a Windows device name gets a suffix.
+func Sanitize(name string, maxBytes int) string {
+	if maxBytes <= 0 || maxBytes > MaxBytes {
+		maxBytes = MaxBytes
+	}
+	name = strings.ToValidUTF8(name, string(replacement))
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, r == 0x7f:
+			return replacement
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return replacement
+		default:
+			return r
+		}
+	}, name)
+	name = trim(Truncate(trim(name), maxBytes))
+	base := name
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if reserved[strings.ToUpper(base)] {
+		name = Truncate(base+string(replacement)+name[len(base):], maxBytes)
+	}
+	if len(name) == 0 || name == "." || name == ".." {
+		return string(replacement)
+	}
+	return name
+}
+
+// trim removes the leading spaces and the trailing dots and spaces Windows does not keep
+func trim(name string) string {
+	return strings.TrimRight(strings.TrimLeft(name, " "), ". ")
+}
+
+// Truncate cuts name to at most maxBytes without breaking a UTF-8 character, the extension is kept
+// unless it is too long to leave room for the rest of the name
+func Truncate(name string, maxBytes int) string {
+	if len(name) <= maxBytes {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) >= maxBytes/2 {
+		ext = ""
+	}
+	base := name[:len(name)-len(ext)]
+	n := maxBytes - len(ext)
+	for n > 0 && !utf8.RuneStart(base[n]) {
+		n--
+	}
+	return strings.TrimRight(base[:n], ". ") + ext
+}
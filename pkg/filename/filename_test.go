@@ -0,0 +1,42 @@
+package filename
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := map[string]string{
+		"AC/DC: Live?":      "AC_DC_ Live_",
+		`a\b*c"d<e>f|g`:     "a_b_c_d_e_f_g",
+		"tab\tnew\nline":    "tab_new_line",
+		"  title. . ":       "title",
+		"emoji 🎸 title.mp4": "emoji 🎸 title.mp4",
+		"con.mp4":           "con_.mp4",
+		"LPT1":              "LPT1_",
+		"console.mp4":       "console.mp4",
+		"":                  "_",
+		"..":                "_",
+		"invalid \xff utf8": "invalid _ utf8",
+		"中文标题【4K】":          "中文标题【4K】",
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, Sanitize(name, 0), name)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	name := strings.Repeat("视频", 100) + ".mp4"
+	truncated := Sanitize(name, 0)
+	assert.LessOrEqual(t, len(truncated), MaxBytes)
+	assert.True(t, utf8.ValidString(truncated))
+	assert.True(t, strings.HasSuffix(truncated, "视频.mp4") || strings.HasSuffix(truncated, "视.mp4"))
+
+	assert.Equal(t, "abcdef.mp4", Truncate("abcdefghij.mp4", 10))
+	// the extension is dropped when it leaves no room for the name
+	assert.Equal(t, "abcde", Truncate("abcdefghij.verylongextension", 5))
+	assert.Equal(t, "short.mp4", Truncate("short.mp4", 100))
+}
@@ -0,0 +1,112 @@
+package flv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// tag types
+const (
+	TagAudio  = 8
+	TagVideo  = 9
+	TagScript = 18
+)
+
+const (
+	headerSize    = 9
+	tagHeaderSize = 11
+)
+
+var signature = []byte("FLV")
+
+// Concat joins flv segments of the same stream into w. The script tags (onMetaData) of the
+// segments after the first one are dropped and their timestamps continue from the previous segment.
+func Concat(w io.Writer, segments ...io.Reader) error {
+	var (
+		writer = bufio.NewWriter(w)
+		offset uint32
+	)
+	for i, segment := range segments {
+		reader := bufio.NewReader(segment)
+		header := make([]byte, headerSize+4)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return fmt.Errorf("segment %d: %w", i+1, err)
+		}
+		if !bytes.Equal(header[:3], signature) {
+			return fmt.Errorf("segment %d: not a flv file", i+1)
+		}
+		// skip the rest of a header longer than 9 bytes
+		if size := binary.BigEndian.Uint32(header[5:9]); size > headerSize {
+			if _, err := reader.Discard(int(size - headerSize)); err != nil {
+				return fmt.Errorf("segment %d: %w", i+1, err)
+			}
+			header = append(header[:headerSize], 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(header[5:9], headerSize)
+		}
+		if i == 0 {
+			if _, err := writer.Write(header); err != nil {
+				return err
+			}
+		}
+		end, err := copyTags(writer, reader, offset, i == 0)
+		if err != nil {
+			return fmt.Errorf("segment %d: %w", i+1, err)
+		}
+		offset = end
+	}
+	return writer.Flush()
+}
+
+// copyTags copies the tags of r with their timestamps moved by offset,
+// it returns where the next segment starts: the last timestamp plus the last frame interval
+func copyTags(w io.Writer, r io.Reader, offset uint32, withScript bool) (uint32, error) {
+	var (
+		tagHeader = make([]byte, tagHeaderSize)
+		last      = make(map[byte]uint32)
+		interval  = make(map[byte]uint32)
+		end       = offset
+	)
+	for {
+		if _, err := io.ReadFull(r, tagHeader); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		var (
+			tagType   = tagHeader[0] & 0x1F
+			dataSize  = uint32(tagHeader[1])<<16 | uint32(tagHeader[2])<<8 | uint32(tagHeader[3])
+			timestamp = uint32(tagHeader[7])<<24 | uint32(tagHeader[4])<<16 | uint32(tagHeader[5])<<8 | uint32(tagHeader[6])
+		)
+		body := make([]byte, dataSize+4)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return 0, err
+		}
+		if tagType == TagScript && !withScript {
+			continue
+		}
+		if previous, ok := last[tagType]; ok && timestamp > previous {
+			interval[tagType] = timestamp - previous
+		}
+		last[tagType] = timestamp
+		timestamp += offset
+		if next := timestamp + interval[tagType]; tagType != TagScript && next > end {
+			end = next
+		}
+		tagHeader[4], tagHeader[5], tagHeader[6], tagHeader[7] = byte(timestamp>>16), byte(timestamp>>8), byte(timestamp), byte(timestamp>>24)
+		if _, err := w.Write(tagHeader); err != nil {
+			return 0, err
+		}
+		if _, err := w.Write(body); err != nil {
+			return 0, err
+		}
+	}
+	return end, nil
+}
+
+// IsFLV reports whether the header of data is the flv signature
+func IsFLV(data []byte) bool {
+	return len(data) >= 3 && bytes.Equal(data[:3], signature)
+}
@@ -0,0 +1,81 @@
+package flv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tag struct {
+	tagType   byte
+	timestamp uint32
+	data      []byte
+}
+
+func build(tags ...tag) []byte {
+	buf := &bytes.Buffer{}
+	buf.Write([]byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0})
+	for _, t := range tags {
+		size := len(t.data)
+		buf.Write([]byte{t.tagType, byte(size >> 16), byte(size >> 8), byte(size),
+			byte(t.timestamp >> 16), byte(t.timestamp >> 8), byte(t.timestamp), byte(t.timestamp >> 24), 0, 0, 0})
+		buf.Write(t.data)
+		_ = binary.Write(buf, binary.BigEndian, uint32(size+tagHeaderSize))
+	}
+	return buf.Bytes()
+}
+
+func parse(t *testing.T, data []byte) []tag {
+	assert.True(t, IsFLV(data))
+	r := bytes.NewReader(data[headerSize+4:])
+	tags := make([]tag, 0)
+	for {
+		header := make([]byte, tagHeaderSize)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return tags
+		}
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		body := make([]byte, size+4)
+		_, err := io.ReadFull(r, body)
+		assert.NoError(t, err)
+		tags = append(tags, tag{
+			tagType:   header[0],
+			timestamp: uint32(header[7])<<24 | uint32(header[4])<<16 | uint32(header[5])<<8 | uint32(header[6]),
+			data:      body[:size],
+		})
+	}
+}
+
+func TestConcat(t *testing.T) {
+	first := build(
+		tag{TagScript, 0, []byte("meta")},
+		tag{TagVideo, 0, []byte("v0")},
+		tag{TagAudio, 0, []byte("a0")},
+		tag{TagVideo, 40, []byte("v1")},
+		tag{TagAudio, 23, []byte("a1")},
+	)
+	second := build(
+		tag{TagScript, 0, []byte("meta")},
+		tag{TagVideo, 0, []byte("v2")},
+		tag{TagVideo, 40, []byte("v3")},
+	)
+	out := &bytes.Buffer{}
+	assert.NoError(t, Concat(out, bytes.NewReader(first), bytes.NewReader(second)))
+	assert.Equal(t, []tag{
+		{TagScript, 0, []byte("meta")},
+		{TagVideo, 0, []byte("v0")},
+		{TagAudio, 0, []byte("a0")},
+		{TagVideo, 40, []byte("v1")},
+		{TagAudio, 23, []byte("a1")},
+		{TagVideo, 80, []byte("v2")},
+		{TagVideo, 120, []byte("v3")},
+	}, parse(t, out.Bytes()))
+}
+
+func TestConcatNotFLV(t *testing.T) {
+	err := Concat(io.Discard, bytes.NewReader([]byte("ftypisom.........")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,212 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// containers are the boxes whose children are parsed, the others are kept as raw payloads
+var containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"mvex": true,
+	"moof": true,
+	"traf": true,
+}
+
+// box is an iso bmff box, children is set for containers and payload for the others
+type box struct {
+	typ      string
+	payload  []byte
+	children []*box
+	// large keeps the 64-bit size of the original box
+	large bool
+}
+
+// header is the position of a top level box in a file
+type header struct {
+	typ        string
+	offset     int64
+	size       int64
+	headerSize int64
+}
+
+// readHeader reads the header of the box at offset, a size of 0 extends to the end
+func readHeader(r io.ReaderAt, offset, end int64) (header, error) {
+	buf := make([]byte, 16)
+	if _, err := r.ReadAt(buf[:8], offset); err != nil {
+		return header{}, err
+	}
+	h := header{
+		typ:        string(buf[4:8]),
+		offset:     offset,
+		size:       int64(binary.BigEndian.Uint32(buf[:4])),
+		headerSize: 8,
+	}
+	switch h.size {
+	case 0:
+		h.size = end - offset
+	case 1:
+		if _, err := r.ReadAt(buf[8:16], offset+8); err != nil {
+			return header{}, err
+		}
+		h.size = int64(binary.BigEndian.Uint64(buf[8:16]))
+		h.headerSize = 16
+	}
+	if h.size < h.headerSize || offset+h.size > end {
+		return header{}, fmt.Errorf("invalid %s box at %d", h.typ, offset)
+	}
+	return h, nil
+}
+
+// scan returns the top level boxes of a file of size bytes
+func scan(r io.ReaderAt, size int64) ([]header, error) {
+	headers := make([]header, 0)
+	for offset := int64(0); offset < size; {
+		h, err := readHeader(r, offset, size)
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, h)
+		offset += h.size
+	}
+	return headers, nil
+}
+
+// readBox reads and parses the top level box of h
+func readBox(r io.ReaderAt, h header) (*box, error) {
+	data := make([]byte, h.size)
+	if _, err := r.ReadAt(data, h.offset); err != nil {
+		return nil, err
+	}
+	boxes, err := parseBoxes(data)
+	if err != nil {
+		return nil, err
+	}
+	return boxes[0], nil
+}
+
+// parseBoxes parses a sequence of boxes, the children of containers are parsed recursively
+func parseBoxes(data []byte) ([]*box, error) {
+	boxes := make([]*box, 0)
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("truncated box")
+		}
+		var (
+			size       = uint64(binary.BigEndian.Uint32(data[:4]))
+			typ        = string(data[4:8])
+			headerSize = uint64(8)
+			large      bool
+		)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, fmt.Errorf("truncated %s box", typ)
+			}
+			size, headerSize, large = binary.BigEndian.Uint64(data[8:16]), 16, true
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			return nil, fmt.Errorf("invalid %s box size %d", typ, size)
+		}
+		b := &box{typ: typ, large: large}
+		payload := data[headerSize:size]
+		if containers[typ] {
+			children, err := parseBoxes(payload)
+			if err != nil {
+				return nil, err
+			}
+			b.children = children
+		} else {
+			b.payload = payload
+		}
+		boxes = append(boxes, b)
+		data = data[size:]
+	}
+	return boxes, nil
+}
+
+func (b *box) size() int64 {
+	size := int64(8)
+	if b.large {
+		size = 16
+	}
+	if b.children == nil {
+		return size + int64(len(b.payload))
+	}
+	for _, child := range b.children {
+		size += child.size()
+	}
+	return size
+}
+
+func (b *box) bytes() []byte {
+	size := b.size()
+	buf := make([]byte, 0, size)
+	if b.large {
+		buf = appendUint32(buf, 1)
+		buf = append(buf, b.typ...)
+		buf = appendUint64(buf, uint64(size))
+	} else {
+		buf = appendUint32(buf, uint32(size))
+		buf = append(buf, b.typ...)
+	}
+	if b.children == nil {
+		return append(buf, b.payload...)
+	}
+	for _, child := range b.children {
+		buf = append(buf, child.bytes()...)
+	}
+	return buf
+}
+
+// child returns the first child of typ
+func (b *box) child(typ string) *box {
+	for _, child := range b.children {
+		if child.typ == typ {
+			return child
+		}
+	}
+	return nil
+}
+
+// find follows the path of box types from b
+func (b *box) find(path ...string) *box {
+	for _, typ := range path {
+		if b = b.child(typ); b == nil {
+			return nil
+		}
+	}
+	return b
+}
+
+func (b *box) all(typ string) []*box {
+	boxes := make([]*box, 0)
+	for _, child := range b.children {
+		if child.typ == typ {
+			boxes = append(boxes, child)
+		}
+	}
+	return boxes
+}
+
+// version and flags of a full box
+func (b *box) version() byte {
+	return b.payload[0]
+}
+
+func (b *box) flags() uint32 {
+	return binary.BigEndian.Uint32(b.payload[:4]) & 0xFFFFFF
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(v>>32)), uint32(v))
+}
@@ -0,0 +1,350 @@
+package mp4
+
+import (
+	"container/heap"
+	"encoding/binary"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrNotFragmented is returned when an input is not a fragmented mp4 with a single track
+var ErrNotFragmented = stderrors.New("not a fragmented mp4 with a single track")
+
+const tfhdBaseDataOffset = 0x000001
+
+// track is a fragmented mp4 input with a single track
+type track struct {
+	r         *io.SectionReader
+	ftyp      *box
+	moov      *box
+	timescale uint32
+	fragments []*fragment
+}
+
+// fragment is a moof and the boxes after it up to its last mdat
+type fragment struct {
+	moof   *box
+	offset int64
+	boxes  []header
+	// time is the decode time of the fragment in seconds
+	time float64
+}
+
+// RemuxFiles combines the fragmented mp4 inputs, e.g. the video and audio m4s of a dash stream, into output
+func RemuxFiles(output string, inputs ...string) error {
+	readers := make([]*io.SectionReader, 0, len(inputs))
+	for _, input := range inputs {
+		file, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		readers = append(readers, io.NewSectionReader(file, 0, info.Size()))
+	}
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err = Remux(file, readers...); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Remux combines fragmented mp4 inputs with one track each into a fragmented mp4 written to w.
+// The samples are copied without re-encoding and the fragments are interleaved by decode time.
+func Remux(w io.Writer, inputs ...*io.SectionReader) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("nothing to remux")
+	}
+	tracks := make([]*track, 0, len(inputs))
+	for i, input := range inputs {
+		t, err := loadTrack(input)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", i+1, err)
+		}
+		tracks = append(tracks, t)
+	}
+	out := &countWriter{w: w}
+	ftyp := tracks[0].ftyp
+	if ftyp == nil {
+		ftyp = &box{typ: "ftyp", payload: []byte("iso6\x00\x00\x00\x00iso6mp41")}
+	}
+	if _, err := out.Write(ftyp.bytes()); err != nil {
+		return err
+	}
+	if _, err := out.Write(mergeMoov(tracks).bytes()); err != nil {
+		return err
+	}
+	return writeFragments(out, tracks)
+}
+
+func loadTrack(r *io.SectionReader) (*track, error) {
+	headers, err := scan(r, r.Size())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		t       = &track{r: r}
+		current *fragment
+		pending []header
+	)
+	for _, h := range headers {
+		switch h.typ {
+		case "ftyp":
+			if t.ftyp, err = readBox(r, h); err != nil {
+				return nil, err
+			}
+		case "moov":
+			if t.moov, err = readBox(r, h); err != nil {
+				return nil, err
+			}
+		case "moof":
+			moof, err := readBox(r, h)
+			if err != nil {
+				return nil, err
+			}
+			current = &fragment{moof: moof, offset: h.offset}
+			t.fragments = append(t.fragments, current)
+			pending = nil
+		case "mdat":
+			if current == nil {
+				return nil, ErrNotFragmented
+			}
+			current.boxes = append(current.boxes, pending...)
+			current.boxes = append(current.boxes, h)
+			pending = nil
+		case "sidx", "mfra", "styp":
+			// indexes of the input, they do not apply to the output
+		default:
+			pending = append(pending, h)
+		}
+	}
+	if t.moov == nil || len(t.moov.all("trak")) != 1 || len(t.fragments) == 0 {
+		return nil, ErrNotFragmented
+	}
+	tkhd, mdhd := t.moov.find("trak", "tkhd"), t.moov.find("trak", "mdia", "mdhd")
+	if !fullBox(t.moov.child("mvhd"), 100, 112) || !fullBox(tkhd, 16, 24) || !fullBox(mdhd, 16, 24) {
+		return nil, ErrNotFragmented
+	}
+	if trex := t.moov.find("mvex", "trex"); trex != nil && !fullBox(trex, 24, 24) {
+		return nil, ErrNotFragmented
+	}
+	if mdhd.version() == 1 {
+		t.timescale = binary.BigEndian.Uint32(mdhd.payload[20:24])
+	} else {
+		t.timescale = binary.BigEndian.Uint32(mdhd.payload[12:16])
+	}
+	if t.timescale == 0 {
+		return nil, fmt.Errorf("invalid timescale")
+	}
+	var last float64
+	for _, f := range t.fragments {
+		if !validTrafs(f.moof) {
+			return nil, ErrNotFragmented
+		}
+		f.time = last
+		if tfdt := f.moof.find("traf", "tfdt"); fullBox(tfdt, 8, 12) {
+			if tfdt.version() == 1 {
+				f.time = float64(binary.BigEndian.Uint64(tfdt.payload[4:12])) / float64(t.timescale)
+			} else {
+				f.time = float64(binary.BigEndian.Uint32(tfdt.payload[4:8])) / float64(t.timescale)
+			}
+		}
+		last = f.time
+	}
+	return t, nil
+}
+
+// validTrafs reports whether moof has a traf and every traf has a tfhd long enough to rewrite
+func validTrafs(moof *box) bool {
+	trafs := moof.all("traf")
+	for _, traf := range trafs {
+		tfhd := traf.child("tfhd")
+		if !fullBox(tfhd, 8, 8) || (tfhd.flags()&tfhdBaseDataOffset != 0 && len(tfhd.payload) < 16) {
+			return false
+		}
+	}
+	return len(trafs) > 0
+}
+
+// mergeMoov puts the trak of every track into the moov of the first one, track i gets the id i+1
+func mergeMoov(tracks []*track) *box {
+	var (
+		first    = tracks[0].moov
+		moov     = &box{typ: "moov", large: first.large}
+		mvex     = &box{typ: "mvex"}
+		duration float64
+	)
+	for _, child := range first.children {
+		if child.typ != "trak" && child.typ != "mvex" {
+			moov.children = append(moov.children, child)
+		}
+	}
+	for i, t := range tracks {
+		id := uint32(i + 1)
+		trak := t.moov.child("trak")
+		setTrackID(trak.child("tkhd"), id)
+		moov.children = append(moov.children, trak)
+
+		trex := t.moov.find("mvex", "trex")
+		if trex == nil {
+			trex = &box{typ: "trex", payload: make([]byte, 24)}
+			// default_sample_description_index
+			binary.BigEndian.PutUint32(trex.payload[8:12], 1)
+		}
+		binary.BigEndian.PutUint32(trex.payload[4:8], id)
+		mvex.children = append(mvex.children, trex)
+
+		if d := fragmentDuration(t.moov); d > duration {
+			duration = d
+		}
+	}
+	if mvhd := moov.child("mvhd"); mvhd != nil {
+		binary.BigEndian.PutUint32(mvhd.payload[len(mvhd.payload)-4:], uint32(len(tracks)+1))
+		if duration > 0 {
+			timescale := movieTimescale(mvhd)
+			mehd := &box{typ: "mehd", payload: make([]byte, 12)}
+			mehd.payload[0] = 1
+			binary.BigEndian.PutUint64(mehd.payload[4:12], uint64(duration*float64(timescale)))
+			mvex.children = append([]*box{mehd}, mvex.children...)
+		}
+	}
+	moov.children = append(moov.children, mvex)
+	return moov
+}
+
+// fullBox reports whether b is a full box whose payload is long enough for version 0 or 1
+func fullBox(b *box, v0, v1 int) bool {
+	if b == nil || len(b.payload) < 4 {
+		return false
+	}
+	if b.version() == 1 {
+		return len(b.payload) >= v1
+	}
+	return len(b.payload) >= v0
+}
+
+func setTrackID(tkhd *box, id uint32) {
+	if tkhd.version() == 1 {
+		binary.BigEndian.PutUint32(tkhd.payload[20:24], id)
+	} else {
+		binary.BigEndian.PutUint32(tkhd.payload[12:16], id)
+	}
+}
+
+func movieTimescale(mvhd *box) uint32 {
+	if mvhd.version() == 1 {
+		return binary.BigEndian.Uint32(mvhd.payload[20:24])
+	}
+	return binary.BigEndian.Uint32(mvhd.payload[12:16])
+}
+
+// fragmentDuration returns the duration of mehd in seconds, 0 if unknown
+func fragmentDuration(moov *box) float64 {
+	mvhd, mehd := moov.child("mvhd"), moov.find("mvex", "mehd")
+	if mvhd == nil || !fullBox(mehd, 8, 12) {
+		return 0
+	}
+	timescale := movieTimescale(mvhd)
+	if timescale == 0 {
+		return 0
+	}
+	if mehd.version() == 1 {
+		return float64(binary.BigEndian.Uint64(mehd.payload[4:12])) / float64(timescale)
+	}
+	return float64(binary.BigEndian.Uint32(mehd.payload[4:8])) / float64(timescale)
+}
+
+// writeFragments writes the fragments of all tracks ordered by decode time with new sequence numbers
+func writeFragments(out *countWriter, tracks []*track) error {
+	queue := &fragmentQueue{}
+	for i, t := range tracks {
+		heap.Push(queue, cursor{track: i, t: t})
+	}
+	for sequence := uint32(1); queue.Len() > 0; sequence++ {
+		c := heap.Pop(queue).(cursor)
+		f := c.t.fragments[c.index]
+		if err := writeFragment(out, f, c.t.r, sequence, uint32(c.track+1)); err != nil {
+			return err
+		}
+		if c.index++; c.index < len(c.t.fragments) {
+			heap.Push(queue, c)
+		}
+	}
+	return nil
+}
+
+func writeFragment(out *countWriter, f *fragment, r *io.SectionReader, sequence, id uint32) error {
+	if mfhd := f.moof.child("mfhd"); fullBox(mfhd, 8, 8) {
+		binary.BigEndian.PutUint32(mfhd.payload[4:8], sequence)
+	}
+	for _, traf := range f.moof.all("traf") {
+		tfhd := traf.child("tfhd")
+		binary.BigEndian.PutUint32(tfhd.payload[4:8], id)
+		if tfhd.flags()&tfhdBaseDataOffset != 0 {
+			// the absolute offset moves with the moof
+			base := int64(binary.BigEndian.Uint64(tfhd.payload[8:16]))
+			binary.BigEndian.PutUint64(tfhd.payload[8:16], uint64(base-f.offset+out.n))
+		}
+	}
+	if _, err := out.Write(f.moof.bytes()); err != nil {
+		return err
+	}
+	for _, h := range f.boxes {
+		if _, err := io.Copy(out, io.NewSectionReader(r, h.offset, h.size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type cursor struct {
+	track int
+	index int
+	t     *track
+}
+
+func (c cursor) time() float64 {
+	return c.t.fragments[c.index].time
+}
+
+type fragmentQueue []cursor
+
+func (q fragmentQueue) Len() int { return len(q) }
+
+func (q fragmentQueue) Less(i, j int) bool {
+	if q[i].time() != q[j].time() {
+		return q[i].time() < q[j].time()
+	}
+	return q[i].track < q[j].track
+}
+
+func (q fragmentQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *fragmentQueue) Push(x interface{}) { *q = append(*q, x.(cursor)) }
+
+func (q *fragmentQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
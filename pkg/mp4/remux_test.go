@@ -0,0 +1,180 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mkBox(typ string, payloads ...[]byte) []byte {
+	size := 8
+	for _, payload := range payloads {
+		size += len(payload)
+	}
+	buf := appendUint32(make([]byte, 0, size), uint32(size))
+	buf = append(buf, typ...)
+	for _, payload := range payloads {
+		buf = append(buf, payload...)
+	}
+	return buf
+}
+
+// fullPayload is a version 0 full box payload of size bytes with the flags and the uint32 fields at their offsets
+func fullPayload(size int, flags uint32, fields map[int]uint32) []byte {
+	payload := make([]byte, size)
+	binary.BigEndian.PutUint32(payload[:4], flags)
+	for offset, v := range fields {
+		binary.BigEndian.PutUint32(payload[offset:offset+4], v)
+	}
+	return payload
+}
+
+func mkInit(timescale uint32, handler string) []byte {
+	return mkInitTrex(timescale, handler, mkBox("trex", fullPayload(24, 0, map[int]uint32{4: 1, 8: 1})))
+}
+
+func mkInitTrex(timescale uint32, handler string, trex []byte) []byte {
+	mvhd := mkBox("mvhd", fullPayload(100, 0, map[int]uint32{12: 1000, 96: 2}))
+	tkhd := mkBox("tkhd", fullPayload(84, 3, map[int]uint32{12: 1}))
+	mdhd := mkBox("mdhd", fullPayload(24, 0, map[int]uint32{12: timescale}))
+	hdlr := mkBox("hdlr", append(make([]byte, 8), handler...))
+	mehd := mkBox("mehd", fullPayload(8, 0, map[int]uint32{4: 3000}))
+	return append(
+		mkBox("ftyp", []byte("iso5\x00\x00\x00\x01iso5dash")),
+		mkBox("moov", mvhd, mkBox("trak", tkhd, mkBox("mdia", mdhd, hdlr)), mkBox("mvex", mehd, trex))...,
+	)
+}
+
+// mkFragment returns a moof and mdat at offset, the tfhd has an absolute base offset if absolute is set
+func mkFragment(offset int64, sequence uint32, decodeTime uint64, data []byte, absolute bool) []byte {
+	mfhd := mkBox("mfhd", fullPayload(8, 0, map[int]uint32{4: sequence}))
+	tfdt := mkBox("tfdt", append(fullPayload(4, 1<<24, nil), appendUint64(nil, decodeTime)...))
+	trun := mkBox("trun", fullPayload(12, 1, map[int]uint32{4: 1}))
+	var tfhd []byte
+	if absolute {
+		tfhd = mkBox("tfhd", fullPayload(16, tfhdBaseDataOffset, map[int]uint32{4: 1}))
+	} else {
+		tfhd = mkBox("tfhd", fullPayload(8, 0x020000, map[int]uint32{4: 1}))
+	}
+	moof := mkBox("moof", mfhd, mkBox("traf", tfhd, tfdt, trun))
+	if absolute {
+		// the base points at the payload of the mdat following the moof
+		base := uint64(offset) + uint64(len(moof)) + 8
+		binary.BigEndian.PutUint64(moof[len(mfhd)+8+8+8+8:], base)
+	}
+	return append(moof, mkBox("mdat", data)...)
+}
+
+func mkTrack(timescale uint32, handler string, times []uint64, prefix string, absolute bool) []byte {
+	file := mkInit(timescale, handler)
+	file = append(file, mkBox("sidx", make([]byte, 24))...)
+	for i, decodeTime := range times {
+		data := []byte(prefix + string(rune('0'+i)))
+		file = append(file, mkFragment(int64(len(file)), uint32(i+1), decodeTime, data, absolute)...)
+	}
+	return file
+}
+
+type outFragment struct {
+	sequence uint32
+	trackID  uint32
+	data     string
+}
+
+func TestRemux(t *testing.T) {
+	video := mkTrack(16000, "vide", []uint64{0, 16000, 32000}, "v", false)
+	audio := mkTrack(48000, "soun", []uint64{0, 72000}, "a", true)
+	out := &bytes.Buffer{}
+	err := Remux(out,
+		io.NewSectionReader(bytes.NewReader(video), 0, int64(len(video))),
+		io.NewSectionReader(bytes.NewReader(audio), 0, int64(len(audio))),
+	)
+	assert.NoError(t, err)
+
+	r := bytes.NewReader(out.Bytes())
+	headers, err := scan(r, r.Size())
+	assert.NoError(t, err)
+	types := make([]string, 0)
+	for _, h := range headers {
+		types = append(types, h.typ)
+	}
+	assert.Equal(t, []string{"ftyp", "moov", "moof", "mdat", "moof", "mdat", "moof", "mdat", "moof", "mdat", "moof", "mdat"}, types)
+
+	moov, err := readBox(r, headers[1])
+	assert.NoError(t, err)
+	traks := moov.all("trak")
+	assert.Len(t, traks, 2)
+	assert.Equal(t, uint32(1), binary.BigEndian.Uint32(traks[0].child("tkhd").payload[12:16]))
+	assert.Equal(t, uint32(2), binary.BigEndian.Uint32(traks[1].child("tkhd").payload[12:16]))
+	assert.Equal(t, "soun", string(traks[1].find("mdia", "hdlr").payload[8:12]))
+	trexs := moov.child("mvex").all("trex")
+	assert.Len(t, trexs, 2)
+	assert.Equal(t, uint32(2), binary.BigEndian.Uint32(trexs[1].payload[4:8]))
+	assert.Equal(t, uint32(3), binary.BigEndian.Uint32(moov.child("mvhd").payload[96:100]))
+	assert.NotNil(t, moov.find("mvex", "mehd"))
+
+	fragments := make([]outFragment, 0)
+	for i := 2; i < len(headers); i += 2 {
+		moof, err := readBox(r, headers[i])
+		assert.NoError(t, err)
+		mdat := headers[i+1]
+		data := out.Bytes()[mdat.offset+mdat.headerSize : mdat.offset+mdat.size]
+		tfhd := moof.find("traf", "tfhd")
+		if tfhd.flags()&tfhdBaseDataOffset != 0 {
+			base := binary.BigEndian.Uint64(tfhd.payload[8:16])
+			assert.Equal(t, uint64(mdat.offset+mdat.headerSize), base)
+		}
+		fragments = append(fragments, outFragment{
+			sequence: binary.BigEndian.Uint32(moof.child("mfhd").payload[4:8]),
+			trackID:  binary.BigEndian.Uint32(tfhd.payload[4:8]),
+			data:     string(data),
+		})
+	}
+	assert.Equal(t, []outFragment{
+		{1, 1, "v0"},
+		{2, 2, "a0"},
+		{3, 1, "v1"},
+		{4, 2, "a1"},
+		{5, 1, "v2"},
+	}, fragments)
+}
+
+func TestRemuxNotFragmented(t *testing.T) {
+	file := append(mkBox("ftyp", []byte("isom\x00\x00\x00\x00")), mkBox("mdat", []byte("data"))...)
+	err := Remux(io.Discard, io.NewSectionReader(bytes.NewReader(file), 0, int64(len(file))))
+	assert.ErrorIs(t, err, ErrNotFragmented)
+}
+
+func TestRemuxShortBoxes(t *testing.T) {
+	video := mkTrack(1000, "vide", []uint64{0}, "v", false)
+	shortTrex := mkInitTrex(1000, "vide", mkBox("trex", fullPayload(4, 0, nil)))
+	shortTrex = append(shortTrex, mkFragment(int64(len(shortTrex)), 1, 0, []byte("v0"), false)...)
+
+	// a second fragment whose tfhd claims a base offset it does not have
+	moof := mkBox("moof",
+		mkBox("mfhd", fullPayload(8, 0, map[int]uint32{4: 2})),
+		mkBox("traf", mkBox("tfhd", fullPayload(8, tfhdBaseDataOffset, map[int]uint32{4: 1}))),
+	)
+	shortTfhd := append(append(append([]byte(nil), video...), moof...), mkBox("mdat", []byte("v1"))...)
+
+	for _, file := range [][]byte{shortTrex, shortTfhd} {
+		err := Remux(io.Discard, io.NewSectionReader(bytes.NewReader(file), 0, int64(len(file))))
+		assert.ErrorIs(t, err, ErrNotFragmented)
+	}
+}
+
+func TestRemuxFiles(t *testing.T) {
+	dir := t.TempDir()
+	video, audio, output := filepath.Join(dir, "video.m4s"), filepath.Join(dir, "audio.m4s"), filepath.Join(dir, "out.mp4")
+	assert.NoError(t, os.WriteFile(video, mkTrack(1000, "vide", []uint64{0}, "v", false), 0644))
+	assert.NoError(t, os.WriteFile(audio, mkTrack(1000, "soun", []uint64{0}, "a", false), 0644))
+	assert.NoError(t, RemuxFiles(output, video, audio))
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "ftyp", string(data[4:8]))
+}
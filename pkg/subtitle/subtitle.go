@@ -0,0 +1,171 @@
+package subtitle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Format is the file format a Subtitle is converted to
+type Format string
+
+const (
+	FormatSRT Format = "srt"
+	FormatVTT Format = "vtt"
+	FormatASS Format = "ass"
+)
+
+var Formats = []Format{FormatSRT, FormatVTT, FormatASS}
+
+// ParseFormat parses srt/vtt/ass, case-insensitively
+func ParseFormat(s string) (Format, error) {
+	for _, format := range Formats {
+		if strings.EqualFold(string(format), s) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid subtitle format: %s", s)
+}
+
+// Subtitle is the json body of a bilibili cc subtitle, e.g. https://aisubtitle.hdslb.com/bfs/subtitle/xxx.json
+type Subtitle struct {
+	FontSize        float64 `json:"font_size"`
+	FontColor       string  `json:"font_color"`
+	BackgroundAlpha float64 `json:"background_alpha"`
+	BackgroundColor string  `json:"background_color"`
+	Stroke          string  `json:"Stroke"`
+	Type            string  `json:"type"`
+	Lang            string  `json:"lang"`
+	Version         string  `json:"version"`
+	Body            []Line  `json:"body"`
+}
+
+// Line is a cue of Subtitle, From and To are in seconds
+type Line struct {
+	From     float64 `json:"from"`
+	To       float64 `json:"to"`
+	Sid      int     `json:"sid"`
+	Location int     `json:"location"`
+	Content  string  `json:"content"`
+	Music    float64 `json:"music"`
+}
+
+// Write converts s to format
+func Write(w io.Writer, s *Subtitle, format Format) error {
+	switch format {
+	case FormatSRT:
+		return WriteSRT(w, s)
+	case FormatVTT:
+		return WriteVTT(w, s)
+	case FormatASS:
+		return WriteASS(w, s)
+	default:
+		return fmt.Errorf("invalid subtitle format: %s", format)
+	}
+}
+
+// WriteSRT converts s to SubRip
+func WriteSRT(w io.Writer, s *Subtitle) error {
+	writer := bufio.NewWriter(w)
+	for i, line := range s.Body {
+		_, _ = fmt.Fprintf(writer, "%d\n%s --> %s\n%s\n\n",
+			i+1,
+			timestamp(line.From, ",", 3),
+			timestamp(line.To, ",", 3),
+			line.Content,
+		)
+	}
+	return writer.Flush()
+}
+
+// WriteVTT converts s to WebVTT
+func WriteVTT(w io.Writer, s *Subtitle) error {
+	writer := bufio.NewWriter(w)
+	_, _ = writer.WriteString("WEBVTT\n\n")
+	for _, line := range s.Body {
+		_, _ = fmt.Fprintf(writer, "%s --> %s\n%s\n\n",
+			timestamp(line.From, ".", 3),
+			timestamp(line.To, ".", 3),
+			line.Content,
+		)
+	}
+	return writer.Flush()
+}
+
+const assHeader = `[Script Info]
+ScriptType: v4.00+
+PlayResX: 1920
+PlayResY: 1080
+WrapStyle: 0
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,sans-serif,%d,%s,&H000000FF,&H00000000,%s,0,0,0,0,100,100,0,0,1,2,0,2,60,60,40,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+// WriteASS converts s to Advanced SubStation Alpha
+func WriteASS(w io.Writer, s *Subtitle) error {
+	writer := bufio.NewWriter(w)
+	fontSize := 0.4
+	if s.FontSize > 0 {
+		fontSize = s.FontSize
+	}
+	_, _ = fmt.Fprintf(writer, assHeader,
+		int(math.Round(fontSize*120)),
+		ASSColor(s.FontColor, 0),
+		ASSColor(s.BackgroundColor, 1-s.BackgroundAlpha),
+	)
+	for _, line := range s.Body {
+		text := strings.ReplaceAll(line.Content, "\n", `\N`)
+		// the location of bilibili is the numpad alignment of ass, 2 is the default bottom center
+		if line.Location != 0 && line.Location != 2 {
+			text = fmt.Sprintf(`{\an%d}%s`, line.Location, text)
+		}
+		_, _ = fmt.Fprintf(writer, "Dialogue: 0,%s,%s,Default,,0,0,0,,%s\n",
+			assTimestamp(line.From),
+			assTimestamp(line.To),
+			text,
+		)
+	}
+	return writer.Flush()
+}
+
+// ASSColor converts a #RRGGBB color to &HAABBGGRR, transparency is between 0 (opaque) and 1
+func ASSColor(color string, transparency float64) string {
+	color = strings.TrimPrefix(color, "#")
+	if len(color) != 6 {
+		color = "FFFFFF"
+	}
+	alpha := int(math.Round(math.Max(0, math.Min(1, transparency)) * 255))
+	return fmt.Sprintf("&H%02X%s%s%s", alpha,
+		strings.ToUpper(color[4:6]), strings.ToUpper(color[2:4]), strings.ToUpper(color[0:2]))
+}
+
+// timestamp formats seconds as hh:mm:ss<sep>fraction with digits of fraction
+func timestamp(seconds float64, sep string, digits int) string {
+	h, m, s, fraction := splitSeconds(seconds, digits)
+	return fmt.Sprintf("%02d:%02d:%02d%s%0*d", h, m, s, sep, digits, fraction)
+}
+
+// assTimestamp formats seconds as h:mm:ss.cc
+func assTimestamp(seconds float64) string {
+	h, m, s, fraction := splitSeconds(seconds, 2)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", h, m, s, fraction)
+}
+
+func splitSeconds(seconds float64, digits int) (h, m, s, fraction int64) {
+	if seconds < 0 {
+		seconds = 0
+	}
+	unit := int64(math.Pow10(digits))
+	total := int64(math.Round(seconds * float64(unit)))
+	fraction = total % unit
+	total /= unit
+	return total / 3600, total / 60 % 60, total % 60, fraction
+}
@@ -0,0 +1,52 @@
+package subtitle
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSubtitle = &Subtitle{
+	FontSize:        0.4,
+	FontColor:       "#FFFFFF",
+	BackgroundAlpha: 0.5,
+	BackgroundColor: "#9C27B0",
+	Body: []Line{
+		{From: 0.5, To: 2.25, Location: 2, Content: "你好"},
+		{From: 3661.5, To: 3663, Location: 8, Content: "hello\nworld"},
+	},
+}
+
+func TestWriteSRT(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, Write(buffer, testSubtitle, FormatSRT))
+	assert.Equal(t, "1\n00:00:00,500 --> 00:00:02,250\n你好\n\n"+
+		"2\n01:01:01,500 --> 01:01:03,000\nhello\nworld\n\n", buffer.String())
+}
+
+func TestWriteVTT(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, Write(buffer, testSubtitle, FormatVTT))
+	assert.Equal(t, "WEBVTT\n\n"+
+		"00:00:00.500 --> 00:00:02.250\n你好\n\n"+
+		"01:01:01.500 --> 01:01:03.000\nhello\nworld\n\n", buffer.String())
+}
+
+func TestWriteASS(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, Write(buffer, testSubtitle, FormatASS))
+	ass := buffer.String()
+	assert.Contains(t, ass, "Style: Default,sans-serif,48,&H00FFFFFF,&H000000FF,&H00000000,&H80B0279C,")
+	assert.True(t, strings.HasSuffix(ass, "Dialogue: 0,0:00:00.50,0:00:02.25,Default,,0,0,0,,你好\n"+
+		"Dialogue: 0,1:01:01.50,1:01:03.00,Default,,0,0,0,,{\\an8}hello\\Nworld\n"))
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("VTT")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatVTT, format)
+	_, err = ParseFormat("txt")
+	assert.Error(t, err)
+}